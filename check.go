@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/dsabsay/lazylit/site"
+)
+
+// ## The `check` command
+// `lazylit check` lints the whole `artifacts/` tree without writing `docs/`,
+// so it can run as a pre-commit hook or in CI. See `site.Check`.
+
+func runCheck(args []string) {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	format := fs.String("format", "text", "Output format: text or json.")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: lazylit check [-format text|json]\n\n"+
+			"    Validate every file under artifacts/ without generating docs/.\n"+
+			"    Exits with status 1 if any problem is found.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() > 0 || (*format != "text" && *format != "json") {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	s, err := site.Check(context.Background(), siteOptions())
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	if *format == "json" {
+		if err := s.Problems.ReportJSON(os.Stdout); err != nil {
+			log.Fatal(err.Error())
+		}
+	} else {
+		s.Problems.Report(os.Stdout)
+	}
+	if len(s.Problems) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/dsabsay/lazylit/site"
+)
+
+// ## The `diff` command
+// A build links the diffs between adjacent revisions of every artifact.
+// `lazylit diff` renders the diff between any two revisions, for when the
+// interesting change spans several of them.
+
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: lazylit diff artifact revision revision\n\n"+
+			"    Render the changes between two revisions of an artifact into\n"+
+			"    docs/<artifact>/. A revision is a page name such as\n"+
+			"    tiddlylisp.may_16_2020, or just its date, may_16_2020.\n")
+	}
+	fs.Parse(args)
+	if fs.NArg() != 3 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	s, err := site.Scan(context.Background(), siteOptions())
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	d, err := findDiff(s, fs.Arg(0), fs.Arg(1), fs.Arg(2))
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	dest, err := s.WriteDiff(d)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	fmt.Printf("Created %v\n", dest)
+}
+
+// the diff between two revisions of `name`, in either order
+func findDiff(s *site.Site, name, rev1, rev2 string) (site.Diff, error) {
+	for _, a := range s.Artifacts {
+		if a.Name != name {
+			continue
+		}
+		older, err := findRevision(a, rev1)
+		if err != nil {
+			return site.Diff{}, err
+		}
+		newer, err := findRevision(a, rev2)
+		if err != nil {
+			return site.Diff{}, err
+		}
+		if newer.CommitDate.Before(older.CommitDate) {
+			older, newer = newer, older
+		}
+		return site.Diff{Older: older, Newer: newer}, nil
+	}
+	return site.Diff{}, fmt.Errorf("No artifact named %q", name)
+}
+
+// the snapshot of `a` whose page is called `rev`, or ends in the date `rev`
+func findRevision(a site.Artifact, rev string) (site.Snapshot, error) {
+	var names []string
+	for _, snap := range a.Snapshots {
+		page := snap.PageName()
+		if page == rev || strings.HasSuffix(page, "."+rev) || strings.HasSuffix(page, "_"+rev) {
+			return snap, nil
+		}
+		names = append(names, page)
+	}
+	return site.Snapshot{}, fmt.Errorf("%v has no revision %q; try one of %v", a.Name, rev, strings.Join(names, ", "))
+}
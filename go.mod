@@ -1,8 +1,10 @@
 module github.com/dsabsay/lazylit
 
-go 1.14
+go 1.16
 
 require (
 	github.com/alecthomas/chroma v0.8.0
 	github.com/russross/blackfriday v1.5.2
+	github.com/yuin/goldmark v1.7.8
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/dsabsay/lazylit/site"
+)
+
+// ## The `init` command
+// `lazylit init` scaffolds a new site repository so that nobody has to copy
+// the lazylit-example repo by hand. It never overwrites existing files, so it
+// is safe to run inside a repository that already has some of these pieces.
+
+// the artifact created by `init`, so that the first run of `lazylit` has
+// something to render
+const starterArtifactName = "hello"
+
+// `StarterData` is handed to the `STARTER_ARTIFACT`, `README_MD` and
+// `LAZYLIT_YAML` templates
+type StarterData struct {
+	ArtifactName string
+	FileName     string
+	CommitDate   string
+	// the artifacts and output directories, relative to the site
+	Src, Out string
+}
+
+func runInit(args []string) {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: lazylit [-src dir] [-out dir] init [dir]\n\n"+
+			"    Create artifacts/, docs/, docs/.nojekyll, a starter artifact and a\n"+
+			"    README in dir (default: the current directory). With -src or -out,\n"+
+			"    those directories, relative to dir, take the place of artifacts/\n"+
+			"    and docs/, and a lazylit.yaml naming them is written too.\n")
+	}
+	fs.Parse(args)
+
+	root := "."
+	if fs.NArg() > 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if fs.NArg() == 1 {
+		root = fs.Arg(0)
+	}
+
+	src, out := *srcFlag, *outFlag
+	if src == "" {
+		src = "artifacts"
+	}
+	if out == "" {
+		out = "docs"
+	}
+	if filepath.IsAbs(src) || filepath.IsAbs(out) {
+		log.Fatal("init: -src and -out must be relative to the new site")
+	}
+	if err := initSite(root, src, out, time.Now()); err != nil {
+		log.Fatal(err.Error())
+	}
+	fmt.Printf("Initialized lazylit site in %v\n", root)
+	fmt.Printf("Edit the starter artifact under %v/, then run `lazylit` to generate %v/.\n", src, out)
+}
+
+// create the skeleton of a site under `root`, with the artifacts in `src`
+// and the output in `out`, both relative to `root`
+func initSite(root, src, out string, now time.Time) error {
+	data := StarterData{
+		ArtifactName: starterArtifactName,
+		FileName:     starterArtifactName + "." + dateSuffix(now) + ".go",
+		CommitDate:   now.Format("Jan 2 2006"),
+		Src:          filepath.ToSlash(filepath.Clean(src)),
+		Out:          filepath.ToSlash(filepath.Clean(out)),
+	}
+	srcDir, outDir := filepath.Join(root, src), filepath.Join(root, out)
+
+	for _, dir := range []string{srcDir, outDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	// only drop the starter artifact into a fresh artifacts directory
+	existing, err := ioutil.ReadDir(srcDir)
+	if err != nil {
+		return err
+	}
+	if len(existing) == 0 {
+		artifactDir := filepath.Join(srcDir, data.ArtifactName)
+		if err := os.MkdirAll(artifactDir, 0755); err != nil {
+			return err
+		}
+		err := writeTemplateFile(filepath.Join(artifactDir, data.FileName), STARTER_ARTIFACT, data)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := writeNewFile(filepath.Join(outDir, ".nojekyll"), nil); err != nil {
+		return err
+	}
+	// so that plain `lazylit` finds the directories again
+	if data.Src != "artifacts" || data.Out != "docs" {
+		err := writeTemplateFile(filepath.Join(root, site.ConfigFileName), LAZYLIT_YAML, data)
+		if err != nil {
+			return err
+		}
+	}
+	return writeTemplateFile(filepath.Join(root, "README.md"), README_MD, data)
+}
+
+// render `text` with `data` into `name`, unless `name` already exists
+func writeTemplateFile(name, text string, data interface{}) error {
+	t, err := template.New(filepath.Base(name)).Parse(text)
+	if err != nil {
+		return err
+	}
+	buf := new(strings.Builder)
+	if err := t.Execute(buf, data); err != nil {
+		return err
+	}
+	return writeNewFile(name, []byte(buf.String()))
+}
+
+// write `data` to `name`, leaving any existing file untouched
+func writeNewFile(name string, data []byte) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		log.Printf("init: %v already exists, leaving it alone", name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	log.Printf("init: created %v", name)
+	return f.Close()
+}
+
+// format a date the way artifact file names expect it, e.g. `jul_18_2020`
+func dateSuffix(t time.Time) string {
+	return fmt.Sprintf("%v_%d_%d", strings.ToLower(t.Format("Jan")), t.Day(), t.Year())
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dsabsay/lazylit/site"
+)
+
+// ## The `new` command
+// `lazylit new` imports a file, exactly as it was at a given commit, from a
+// local git repository into `artifacts/` and fills in the headers that
+// a build requires. All the information comes from git itself.
+
+func runNew(args []string) {
+	fs := flag.NewFlagSet("new", flag.ExitOnError)
+	repo := fs.String("repo", ".", "Path to a local clone of the source repository.")
+	commit := fs.String("commit", "HEAD", "Commit (or any git revision) to import the file from.")
+	file := fs.String("file", "", "Path of the file within the repository.")
+	name := fs.String("name", "", "Artifact name, i.e. the subdirectory of artifacts/ (default: file name without extension).")
+	remote := fs.String("remote", "origin", "Git remote used to build SourceLink.")
+	forge := fs.String("forge", "", "Hosting flavour of the remote for SourceLink: github, gitlab, bitbucket or gitea (default: guessed from the host name).")
+	link := fs.String("link", "", "Use this SourceLink instead of deriving one from the remote.")
+	author := fs.String("author", "", "DocAuthor header (default: git config user.name of the site's repository, or default_author from the config file).")
+	sidecar := fs.Bool("sidecar", false, "Leave the file untouched and start a Markdown notes file next to it, for formats without comments such as JSON.")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: lazylit new -file path [flags]\n\n"+
+			"    Copy a file at a specific commit from a local git repository into\n"+
+			"    artifacts/<name>/ and fill in its headers.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *file == "" || fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	snap, err := importSnapshot(importOptions{
+		Repo:    *repo,
+		Commit:  *commit,
+		File:    filepath.ToSlash(*file),
+		Name:    *name,
+		Remote:  *remote,
+		Forge:   *forge,
+		Link:    *link,
+		Author:  *author,
+		Sidecar: *sidecar,
+	})
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	fmt.Printf("Created %v\n", snap.DocFileName)
+}
+
+type importOptions struct {
+	Repo   string
+	Commit string
+	File   string // slash-separated path within `Repo`
+	Name   string
+	Remote string
+	Forge  string
+	Link   string
+	Author string
+	// write a sidecar notes file instead of adding headers to the source
+	Sidecar bool
+}
+
+// read `File` at `Commit` and write it, with headers, under the artifacts
+// directory
+func importSnapshot(opts importOptions) (*site.Snapshot, error) {
+	sha, err := git(opts.Repo, "rev-parse", "--verify", opts.Commit+"^{commit}")
+	if err != nil {
+		return nil, err
+	}
+	blob, err := site.Git(opts.Repo, "show", sha+":"+opts.File)
+	if err != nil {
+		return nil, err
+	}
+	c := currentConfig()
+	langs, err := site.NewLanguages(c)
+	if err != nil {
+		return nil, err
+	}
+	// sidecar sources need no comments, so any language will do
+	var language *site.Language
+	if !opts.Sidecar {
+		if language, err = langs.Detect(opts.File, blob); err != nil {
+			return nil, fmt.Errorf("%v: %v", opts.File, err)
+		}
+	}
+	commitTime, err := gitCommitTime(opts.Repo, sha)
+	if err != nil {
+		return nil, err
+	}
+
+	a := site.Snapshot{
+		ArtifactName:     opts.Name,
+		Commit:           sha,
+		CommitDate:       commitTime,
+		CommitDateString: commitTime.Format("Jan 2 2006"),
+		SourceFileName:   opts.File,
+		SourceLink:       opts.Link,
+		DocAuthor:        opts.Author,
+	}
+	base := path.Base(opts.File)
+	ext := filepath.Ext(base)
+	if a.ArtifactName == "" {
+		a.ArtifactName = base[:len(base)-len(ext)]
+	}
+	if a.DocAuthor == "" {
+		// whoever writes the notes, i.e. works on the site, not whoever
+		// wrote the code. A missing user.name is not fatal, the header just
+		// needs filling in.
+		a.DocAuthor, _ = git(filepath.Dir(*configFlag), "config", "user.name")
+		if a.DocAuthor == "" {
+			a.DocAuthor = c.DefaultAuthor
+		}
+		if a.DocAuthor == "" {
+			log.Printf("new: git config user.name is not set for this site; fill in DocAuthor by hand or pass -author")
+		}
+	}
+	if a.SourceLink == "" {
+		remoteURL, err := git(opts.Repo, "remote", "get-url", opts.Remote)
+		if err != nil {
+			return nil, err
+		}
+		a.SourceLink, err = sourceLink(remoteURL, opts.Forge, sha, opts.File)
+		if err != nil {
+			return nil, fmt.Errorf("%v; pass -link to set SourceLink explicitly", err)
+		}
+	}
+
+	dir := filepath.Join(c.Src, a.ArtifactName)
+	a.DocFileName = filepath.Join(dir, base[:len(base)-len(ext)]+"."+dateSuffix(commitTime)+ext)
+	source := a.DocFileName
+	if opts.Sidecar {
+		a.DocFileName += site.SidecarExt
+	}
+	for _, name := range []string{source, a.DocFileName} {
+		if _, err := os.Stat(name); err == nil {
+			return nil, fmt.Errorf("%v already exists", name)
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{
+		"Commit":     a.Commit,
+		"CommitDate": a.CommitDateString,
+		"SourceFile": a.SourceFileName,
+		"SourceLink": a.SourceLink,
+		"DocAuthor":  a.DocAuthor,
+	}
+	if opts.Sidecar {
+		// the source stays as it is; the headers go in the notes file
+		if err := ioutil.WriteFile(source, blob, 0644); err != nil {
+			return nil, err
+		}
+		buf := bytes.NewBufferString("---\n")
+		for _, h := range site.HeaderNames {
+			fmt.Fprintf(buf, "%v: %v\n", h, values[h])
+		}
+		buf.WriteString("---\n\n")
+		if err := ioutil.WriteFile(a.DocFileName, buf.Bytes(), 0644); err != nil {
+			return nil, err
+		}
+		return &a, nil
+	}
+
+	buf := new(bytes.Buffer)
+	for _, h := range site.HeaderNames {
+		buf.WriteString(language.Header(h, values[h]))
+	}
+	// without a known extension, record the language we detected so the
+	// build doesn't have to guess again
+	if _, ok := langs.ForExtension(ext); !ok {
+		buf.WriteString(language.Header("Language", language.Name()))
+	}
+	buf.WriteString("\n")
+	buf.Write(blob)
+	if err := ioutil.WriteFile(a.DocFileName, buf.Bytes(), 0644); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// the committer date of `sha`, which is what the hosting sites show
+func gitCommitTime(repo, sha string) (time.Time, error) {
+	out, err := git(repo, "show", "-s", "--format=%cI", sha)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, out)
+}
+
+// run git in `repo` and return its trimmed output
+func git(repo string, args ...string) (string, error) {
+	out, err := site.Git(repo, args...)
+	return strings.TrimSpace(string(out)), err
+}
+
+// ### Source links
+// Each hosting site has its own URL scheme for a file at a commit:
+//
+// * GitHub: `https://host/owner/repo/blob/<sha>/<file>`
+// * GitLab: `https://host/owner/repo/-/blob/<sha>/<file>`
+// * Bitbucket: `https://host/owner/repo/src/<sha>/<file>`
+// * Gitea: `https://host/owner/repo/src/commit/<sha>/<file>`
+var forgeLinkFormats = map[string]string{
+	"github":    "%v/blob/%v/%v",
+	"gitlab":    "%v/-/blob/%v/%v",
+	"bitbucket": "%v/src/%v/%v",
+	"gitea":     "%v/src/commit/%v/%v",
+}
+
+// build a `SourceLink` from a git remote URL
+func sourceLink(remoteURL, forge, sha, file string) (string, error) {
+	host, repoPath, err := parseRemoteURL(remoteURL)
+	if err != nil {
+		return "", err
+	}
+	if forge == "" {
+		forge = guessForge(host)
+	}
+	format, ok := forgeLinkFormats[forge]
+	if !ok {
+		return "", fmt.Errorf("Unable to tell how %v links to files", host)
+	}
+	web := "https://" + host + "/" + repoPath
+	return fmt.Sprintf(format, web, sha, file), nil
+}
+
+// guess the hosting site from a host name such as `gitlab.example.com`
+func guessForge(host string) string {
+	for _, forge := range []string{"github", "gitlab", "bitbucket", "gitea"} {
+		if strings.Contains(host, forge) {
+			return forge
+		}
+	}
+	if strings.Contains(host, "codeberg") {
+		return "gitea"
+	}
+	return ""
+}
+
+// split a remote URL into its host name and `owner/repo` path. Handles
+// `https://host/owner/repo.git`, `ssh://git@host:22/owner/repo.git` and the
+// scp-like `git@host:owner/repo.git`.
+func parseRemoteURL(remoteURL string) (host, repoPath string, err error) {
+	if !strings.Contains(remoteURL, "://") {
+		i := strings.Index(remoteURL, ":")
+		if i < 0 {
+			return "", "", fmt.Errorf("Unrecognised remote URL %q", remoteURL)
+		}
+		remoteURL = "ssh://" + remoteURL[:i] + "/" + remoteURL[i+1:]
+	}
+	u, err := url.Parse(remoteURL)
+	if err != nil {
+		return "", "", err
+	}
+	repoPath = strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	if u.Hostname() == "" || repoPath == "" {
+		return "", "", fmt.Errorf("Unrecognised remote URL %q", remoteURL)
+	}
+	// Bitbucket Server style HTTPS clones live under `/scm/`
+	repoPath = strings.TrimPrefix(repoPath, "scm/")
+	// an SSH port says nothing about where the web interface lives
+	host = u.Hostname()
+	if u.Scheme == "http" || u.Scheme == "https" {
+		host = u.Host
+	}
+	return host, repoPath, nil
+}
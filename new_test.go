@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseRemoteURL(t *testing.T) {
+	tests := []struct {
+		url, host, repo string
+	}{
+		{"https://github.com/owner/repo.git", "github.com", "owner/repo"},
+		{"https://github.com/owner/repo", "github.com", "owner/repo"},
+		{"https://gitlab.example.com:8443/group/sub/repo.git/", "gitlab.example.com:8443", "group/sub/repo"},
+		{"https://user@bitbucket.example.com/scm/proj/repo.git", "bitbucket.example.com", "proj/repo"},
+		{"ssh://git@github.com:22/owner/repo.git", "github.com", "owner/repo"},
+		{"git@github.com:owner/repo.git", "github.com", "owner/repo"},
+		{"git@codeberg.org:owner/repo", "codeberg.org", "owner/repo"},
+	}
+	for _, tt := range tests {
+		host, repo, err := parseRemoteURL(tt.url)
+		if err != nil {
+			t.Errorf("parseRemoteURL(%q): %v", tt.url, err)
+			continue
+		}
+		if host != tt.host || repo != tt.repo {
+			t.Errorf("parseRemoteURL(%q) = %q, %q, want %q, %q", tt.url, host, repo, tt.host, tt.repo)
+		}
+	}
+
+	for _, url := range []string{"", "/local/path/repo", "https://github.com/", "git@github.com:"} {
+		if host, repo, err := parseRemoteURL(url); err == nil {
+			t.Errorf("parseRemoteURL(%q) = %q, %q, want an error", url, host, repo)
+		}
+	}
+}
+
+func TestSourceLink(t *testing.T) {
+	tests := []struct {
+		url, forge, want string
+	}{
+		{"git@github.com:owner/repo.git", "", "https://github.com/owner/repo/blob/abc123/dir/file.go"},
+		{"https://gitlab.com/owner/repo.git", "", "https://gitlab.com/owner/repo/-/blob/abc123/dir/file.go"},
+		{"https://bitbucket.org/owner/repo.git", "", "https://bitbucket.org/owner/repo/src/abc123/dir/file.go"},
+		{"https://codeberg.org/owner/repo.git", "", "https://codeberg.org/owner/repo/src/commit/abc123/dir/file.go"},
+		// a self-hosted forge whose name doesn't say what it is
+		{"git@git.example.com:owner/repo.git", "gitlab", "https://git.example.com/owner/repo/-/blob/abc123/dir/file.go"},
+		{"git@git.example.com:owner/repo.git", "", ""},
+		{"git@github.com:owner/repo.git", "sourcehut", ""},
+	}
+	for _, tt := range tests {
+		got, err := sourceLink(tt.url, tt.forge, "abc123", "dir/file.go")
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("sourceLink(%q, %q) = %q, want an error", tt.url, tt.forge, got)
+		case tt.want != "" && err != nil:
+			t.Errorf("sourceLink(%q, %q): %v", tt.url, tt.forge, err)
+		case got != tt.want:
+			t.Errorf("sourceLink(%q, %q) = %q, want %q", tt.url, tt.forge, got, tt.want)
+		}
+	}
+}
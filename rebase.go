@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dsabsay/lazylit/site"
+)
+
+// ## The `rebase` command
+// Upstream code moves on. `lazylit rebase` takes a snapshot and a newer
+// commit of the file it documents and writes a new snapshot: the new code,
+// with the old notes merged in next to the code they describe, using the
+// file at the snapshot's own commit as the common base. Notes whose code is
+// gone, and lines changed both in the notes and upstream, are kept but
+// flagged, so a reviewer can rewrite or drop them.
+
+func runRebase(args []string) {
+	fs := flag.NewFlagSet("rebase", flag.ExitOnError)
+	repo := fs.String("repo", ".", "Path to a local clone of the source repository.")
+	commit := fs.String("commit", "HEAD", "Commit (or any git revision) to rebase the notes onto.")
+	file := fs.String("file", "", "Path of the file within the repository (default: the snapshot's SourceFile).")
+	remote := fs.String("remote", "origin", "Git remote used to build SourceLink.")
+	forge := fs.String("forge", "", "Hosting flavour of the remote for SourceLink: github, gitlab, bitbucket or gitea (default: guessed from the host name).")
+	link := fs.String("link", "", "Use this SourceLink instead of deriving one.")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: lazylit rebase [flags] artifact [revision]\n\n"+
+			"    Write a new snapshot of an artifact from a newer commit of its\n"+
+			"    source, keeping the notes of an existing revision (default: the\n"+
+			"    newest). Notes whose code disappeared, and lines changed both in\n"+
+			"    the notes and upstream, are flagged for review. The repository must\n"+
+			"    have the snapshot's own commit, to merge against.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	s, err := site.Scan(context.Background(), siteOptions())
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	old, err := findSnapshot(s, fs.Arg(0), fs.Arg(1))
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	if *file == "" {
+		*file = old.SourceFileName
+	}
+
+	name, orphans, conflicts, err := rebaseSnapshot(s, old, importOptions{
+		Repo:   *repo,
+		Commit: *commit,
+		File:   filepath.ToSlash(*file),
+		Remote: *remote,
+		Forge:  *forge,
+		Link:   *link,
+	})
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	fmt.Printf("Created %v\n", name)
+	for _, line := range orphans {
+		log.Printf("%v:%v: the code this note described is gone; review it", name, line)
+	}
+	for _, line := range conflicts {
+		log.Printf("%v:%v: these lines changed both in the notes and upstream; merge them by hand", name, line)
+	}
+}
+
+// the snapshot of artifact `name` called `rev`, or its newest one
+func findSnapshot(s *site.Site, name, rev string) (site.Snapshot, error) {
+	for _, a := range s.Artifacts {
+		if a.Name != name {
+			continue
+		}
+		if rev == "" {
+			return a.Snapshots[0], nil
+		}
+		return findRevision(a, rev)
+	}
+	return site.Snapshot{}, fmt.Errorf("No artifact named %q", name)
+}
+
+// write the notes of `old` onto `File` at `Commit`, next to `old`. Returns
+// the new file's name, the lines of the notes that lost their code and those
+// of the conflicts.
+func rebaseSnapshot(s *site.Site, old site.Snapshot, opts importOptions) (string, []int, []int, error) {
+	sha, err := git(opts.Repo, "rev-parse", "--verify", opts.Commit+"^{commit}")
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if strings.EqualFold(sha, old.Commit) {
+		return "", nil, nil, fmt.Errorf("%v is already at %v", old.DocFileName, sha)
+	}
+	blob, err := site.Git(opts.Repo, "show", sha+":"+opts.File)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	// what the notes were written on, to merge against
+	original, err := site.Git(opts.Repo, "show", old.Commit+":"+old.SourceFileName)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("can't read %v at the snapshot's commit %v: %v", old.SourceFileName, old.Commit, err)
+	}
+	commitTime, err := gitCommitTime(opts.Repo, sha)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	link := opts.Link
+	if link == "" && opts.File == old.SourceFileName && strings.Contains(old.SourceLink, old.Commit) {
+		// same file, same host: only the commit changes
+		link = strings.Replace(old.SourceLink, old.Commit, sha, -1)
+	}
+	if link == "" {
+		remoteURL, err := git(opts.Repo, "remote", "get-url", opts.Remote)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		link, err = sourceLink(remoteURL, opts.Forge, sha, opts.File)
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("%v; pass -link to set SourceLink explicitly", err)
+		}
+	}
+
+	base := site.StripDateSuffix(filepath.Base(old.DocFileName))
+	ext := filepath.Ext(base)
+	name := filepath.Join(filepath.Dir(old.DocFileName), base[:len(base)-len(ext)]+"."+dateSuffix(commitTime)+ext)
+	if _, err := os.Stat(name); err == nil {
+		return "", nil, nil, fmt.Errorf("%v already exists", name)
+	}
+	// a snapshot from the same day would share the new one's page
+	page := site.Snapshot{ArtifactName: old.ArtifactName, DocFileName: name}.Destination()
+	for _, a := range s.Artifacts {
+		if a.Name != old.ArtifactName {
+			continue
+		}
+		for _, snap := range a.Snapshots {
+			if snap.Destination() == page {
+				return "", nil, nil, fmt.Errorf("%v would have the same page as %v; rename or remove one of them first", name, snap.DocFileName)
+			}
+		}
+	}
+
+	data, orphans, conflicts, err := s.Rebase(old, original, blob, map[string]string{
+		"Commit":     sha,
+		"CommitDate": commitTime.Format("Jan 2 2006"),
+		"SourceFile": opts.File,
+		"SourceLink": link,
+	})
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if err := ioutil.WriteFile(name, data, 0644); err != nil {
+		return "", nil, nil, err
+	}
+	return name, orphans, conflicts, nil
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dsabsay/lazylit/site"
+)
+
+// ## The `serve` command
+// `lazylit serve` is for writing notes: it builds `docs/`, serves it on
+// localhost, and watches `artifacts/` (and `lazylit.yaml` and `templates/`).
+// When something changes, the site is rebuilt incrementally and every open
+// page is told to reload itself over a server-sent events stream. The reload script is
+// injected into pages as they are served, so `docs/` stays publishable.
+
+// where pages listen for reload events
+const eventsPath = "/_lazylit/events"
+
+// injected right before `</body>` of every HTML page
+const reloadScript = `<script>
+  new EventSource("` + eventsPath + `").onmessage = function () { location.reload(); };
+</script>
+`
+
+// how often `artifacts/` is polled for changes
+const pollInterval = 500 * time.Millisecond
+
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8000", "Address to listen on.")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: lazylit serve [-addr host:port]\n\n"+
+			"    Build docs/, serve it over HTTP and rebuild whenever artifacts/\n"+
+			"    changes, reloading open pages.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	s := &server{clients: make(map[chan struct{}]bool)}
+	state, err := watchState()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	s.rebuild()
+	go s.watch(state)
+
+	log.Printf("serve: serving %v on http://%v/", currentConfig().Out, *addr)
+	log.Fatal(http.ListenAndServe(*addr, s))
+}
+
+// a `server` serves `docs/` and keeps track of the pages waiting for a reload
+type server struct {
+	mu      sync.Mutex
+	clients map[chan struct{}]bool
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == eventsPath {
+		s.events(w, r)
+		return
+	}
+
+	out := currentConfig().Out
+	name := path.Clean("/" + r.URL.Path)
+	if strings.HasSuffix(r.URL.Path, "/") {
+		name = path.Join(name, "index.html")
+	}
+	if strings.HasSuffix(name, ".html") {
+		data, err := ioutil.ReadFile(filepath.Join(out, filepath.FromSlash(name)))
+		if err == nil {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Cache-Control", "no-store")
+			w.Write(injectReload(data))
+			return
+		}
+	}
+	http.FileServer(http.Dir(out)).ServeHTTP(w, r)
+}
+
+// add `reloadScript` to an HTML page
+func injectReload(page []byte) []byte {
+	i := bytes.LastIndex(page, []byte("</body>"))
+	if i < 0 {
+		return append(page, reloadScript...)
+	}
+	out := make([]byte, 0, len(page)+len(reloadScript))
+	out = append(out, page[:i]...)
+	out = append(out, reloadScript...)
+	return append(out, page[i:]...)
+}
+
+// stream a message to the page whenever the site is rebuilt
+func (s *server) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	flusher.Flush()
+
+	reload := make(chan struct{}, 1)
+	s.mu.Lock()
+	s.clients[reload] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, reload)
+		s.mu.Unlock()
+	}()
+
+	select {
+	case <-reload:
+		fmt.Fprint(w, "data: reload\n\n")
+		flusher.Flush()
+	case <-r.Context().Done():
+	}
+}
+
+// tell every open page to reload
+func (s *server) broadcast() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.clients {
+		select {
+		case c <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// rebuild the site; the manifest makes sure only changed artifacts are
+// regenerated
+func (s *server) rebuild() {
+	opts := siteOptions()
+	// rebuilding everything on every save would defeat the purpose
+	opts.Force = false
+	built, err := site.Build(context.Background(), opts)
+	if err != nil {
+		log.Printf("serve: %v", err)
+	} else if len(built.Problems) > 0 {
+		built.Problems.Report(os.Stderr)
+	}
+	s.broadcast()
+}
+
+// ### Watching for changes
+// Polling keeps lazylit free of platform-specific file notification code, and
+// is plenty fast for a directory of hand-edited files.
+
+// size and modification time of a file
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// the state of every file lazylit reads
+func watchState() (map[string]fileState, error) {
+	config := currentConfig()
+	state := make(map[string]fileState)
+	if info, err := os.Stat(*configFlag); err == nil {
+		state[*configFlag] = fileState{info.Size(), info.ModTime()}
+	}
+	record := func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			state[p] = fileState{info.Size(), info.ModTime()}
+		}
+		return nil
+	}
+	err := filepath.Walk(config.Src, record)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("No %v/ directory found. Run `lazylit init` to create one.", config.Src)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// templates are optional
+	if err := filepath.Walk(config.Templates, record); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return state, nil
+}
+
+// poll for changes forever, rebuilding as needed
+func (s *server) watch(state map[string]fileState) {
+	for range time.Tick(pollInterval) {
+		next, err := watchState()
+		if err != nil {
+			log.Printf("serve: %v", err)
+			continue
+		}
+		changed := changedFiles(state, next)
+		state = next
+		if len(changed) == 0 {
+			continue
+		}
+		log.Printf("serve: changed: %v", strings.Join(changed, ", "))
+
+		for _, p := range changed {
+			if p == *configFlag {
+				// languages or note settings may have changed; a broken
+				// config file leaves the last good one in effect
+				if err := setup(); err != nil {
+					log.Printf("serve: %v; keeping the previous configuration", err)
+				}
+				break
+			}
+		}
+		s.rebuild()
+	}
+}
+
+// the paths that differ between two `watchState` results
+func changedFiles(before, after map[string]fileState) []string {
+	var changed []string
+	for p, st := range after {
+		if old, ok := before[p]; !ok || old.size != st.size || !old.modTime.Equal(st.modTime) {
+			changed = append(changed, p)
+		}
+	}
+	for p := range before {
+		if _, ok := after[p]; !ok {
+			changed = append(changed, p)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
@@ -0,0 +1,112 @@
+package site
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strings"
+)
+
+// ## Checking
+// `Check` lints the whole artifacts tree without writing anything, so it can
+// run as a pre-commit hook or in CI. Besides everything a build would
+// complain about, it looks for mistakes that a build happily renders.
+
+// a full or abbreviated SHA-1 or SHA-256 commit hash
+var commitMatcher = regexp.MustCompile(`^[0-9a-fA-F]{7,64}$`)
+
+// find every problem in the site described by `opts`. `opts.OutDir` is
+// ignored.
+func Check(ctx context.Context, opts Options) (*Site, error) {
+	b, err := newBuilder(opts)
+	if err != nil {
+		return nil, err
+	}
+	artifacts, err := b.scan()
+	if err != nil {
+		return nil, err
+	}
+
+	destinations := make(map[string]string)
+	for _, artifact := range artifacts {
+		for _, a := range artifact.Snapshots {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			if other, ok := destinations[a.Destination()]; ok {
+				b.report.add(a.DocFileName, &Diagnostic{File: a.DocFileName, Check: "duplicate-destination",
+					Msg: fmt.Sprintf("renders to %v, like %v", a.Destination(), other)})
+			} else {
+				destinations[a.Destination()] = a.DocFileName
+			}
+			if err := b.checkSnapshot(a); err != nil {
+				b.report.add(a.DocFileName, err)
+			}
+		}
+	}
+	return b.site(artifacts), nil
+}
+
+// the problems with a snapshot whose headers parsed fine
+func (b *builder) checkSnapshot(a Snapshot) error {
+	// sidecar artifacts have their headers in the notes file
+	headerPath, headerParser := a.path, a.language.headerParser
+	if a.notesPath != "" {
+		headerPath, headerParser = a.notesPath, frontMatterHeader
+	}
+	data, err := fs.ReadFile(b.src, headerPath)
+	if err != nil {
+		return err
+	}
+	lines := bytes.Split(data, []byte("\n"))
+	headerLine := func(name string) int {
+		for i := 0; i < a.FirstNonHeaderLine; i++ {
+			if m := headerParser.FindSubmatch(lines[i]); m != nil && string(m[1]) == name {
+				return i + 1
+			}
+		}
+		return 0
+	}
+
+	var problems DiagnosticList
+	problem := func(line int, check, format string, args ...interface{}) {
+		problems = append(problems, &Diagnostic{a.DocFileName, line, check, fmt.Sprintf(format, args...)})
+	}
+
+	if !commitMatcher.MatchString(a.Commit) {
+		problem(headerLine("Commit"), "commit-sha", "Commit: %q is not a hex commit hash", a.Commit)
+	} else if !strings.Contains(strings.ToLower(a.SourceLink), strings.ToLower(a.Commit)) {
+		problem(headerLine("SourceLink"), "source-link", "SourceLink: does not contain the commit %v", a.Commit)
+	}
+
+	_, sections, err := b.load(a)
+	if err != nil {
+		return err
+	}
+	marker := b.marker(a)
+	if !hasNotes(sections) {
+		hint := ""
+		if marker != "" {
+			hint = fmt.Sprintf(" (notes are in marked mode, so they must start with %v%v)", a.language.headerStart, marker)
+		}
+		problem(0, "no-notes", "no documentation comments found%v", hint)
+	}
+
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+// whether any of the `Section`s has documentation
+func hasNotes(sections *list.List) bool {
+	for e := sections.Front(); e != nil; e = e.Next() {
+		if len(bytes.TrimSpace(e.Value.(*Section).docsText)) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package site
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ## Project configuration
+// A site can carry a `lazylit.yaml` next to its `artifacts/` directory. Every
+// setting is optional; a missing file means "use the defaults".
+
+const ConfigFileName = "lazylit.yaml"
+
+// a `Config` mirrors the structure of `lazylit.yaml`
+type Config struct {
+	// Where the artifacts are and where the site goes, relative to the
+	// directory holding `lazylit.yaml`; `artifacts` and `docs` by default
+	Src string `yaml:"src"`
+	Out string `yaml:"out"`
+	// Templates replacing the built-in ones, relative to the directory
+	// holding `lazylit.yaml`; `templates` when loaded from a file and none
+	// otherwise. See `lazylit templates export`.
+	Templates string `yaml:"templates"`
+	// Shown on the front page and in every page title
+	Title string `yaml:"title"`
+	// Where the site is published, e.g. `https://example.github.io/notes`.
+	// Pages name their canonical URL when it is set.
+	BaseURL string `yaml:"base_url"`
+	// Used when an artifact has no `DocAuthor` header
+	DefaultAuthor string `yaml:"default_author"`
+	// The chroma style used to color code, e.g. `monokai`. By default code
+	// is colored like Docco, by the `docco` style.
+	Style string `yaml:"style"`
+	// The chroma style of the dark theme, `monokai` by default. Pages turn
+	// dark when the reader's system prefers it, or with the button on every
+	// page. Empty turns the dark theme off.
+	DarkStyle string `yaml:"dark_style"`
+	// A local clone of the documented repository, relative to the directory
+	// holding `lazylit.yaml`. When set, every page shows how far its
+	// snapshot is behind the clone's HEAD.
+	Upstream string `yaml:"upstream"`
+	// Extra languages, or overrides of the built-in ones, e.g.
+	//
+	//     languages:
+	//       - extension: .tf
+	//         lexer: terraform
+	//         symbol: "#"
+	Languages []LanguageConfig `yaml:"languages"`
+	// Which comments are notes: every comment ("all", the default) or only
+	// those marked with `NoteMarker` ("marked"). Only "marked" pages number
+	// their code lines and link them to the source. Artifacts can override
+	// this with a `Notes` header.
+	Notes string `yaml:"notes"`
+	// What follows the comment delimiter on note lines in "marked" mode,
+	// `>` by default, so `//>` and `#>` start notes
+	NoteMarker string `yaml:"note_marker"`
+	// The Markdown extensions notes are rendered with, on top of plain
+	// CommonMark: `table`, `strikethrough`, `autolink`, `tasklist`,
+	// `footnote`, `heading_ids`, `typographer`, `definition_list` and `math`.
+	// All but the last two by default; an empty list means CommonMark alone.
+	MarkdownExtensions []string `yaml:"markdown_extensions"`
+}
+
+// the `Notes` modes
+const (
+	notesAll    = "all"
+	notesMarked = "marked"
+)
+
+func validNotesMode(mode string) bool {
+	return mode == notesAll || mode == notesMarked
+}
+
+// a `LanguageConfig` adds or overrides an entry in the language registry.
+// Fields left empty keep the built-in value for that extension.
+type LanguageConfig struct {
+	// file extension including the dot, e.g. `.tf`
+	Extension string `yaml:"extension"`
+	// the chroma lexer name or alias
+	Lexer string `yaml:"lexer"`
+	// the line comment delimiter
+	Symbol string `yaml:"symbol"`
+	// block comment delimiters, e.g. `/*` and `*/`
+	BlockStart string `yaml:"block_start"`
+	BlockEnd   string `yaml:"block_end"`
+}
+
+// the settings used when `lazylit.yaml` doesn't say otherwise
+func DefaultConfig() *Config {
+	return &Config{
+		Src:                "artifacts",
+		Out:                "docs",
+		Notes:              notesAll,
+		NoteMarker:         ">",
+		Style:              defaultStyle,
+		DarkStyle:          defaultDarkStyle,
+		MarkdownExtensions: append([]string(nil), defaultMarkdownExtensions...),
+	}
+}
+
+// read `name` into a new `Config`. A missing file is not an error. Relative
+// paths in the file are taken from its directory. On error nothing is
+// returned: a caller reloading the file decides what to keep in effect.
+func LoadConfig(name string) (*Config, error) {
+	c := DefaultConfig()
+	// only a site on disk has a templates directory to look in
+	c.Templates = "templates"
+	data, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		c.Templates = filepath.Join(filepath.Dir(name), c.Templates)
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return nil, fmt.Errorf("%v: %v", name, err)
+	}
+	if !validNotesMode(c.Notes) {
+		return nil, fmt.Errorf("%v: notes must be %q or %q, not %q", name, notesAll, notesMarked, c.Notes)
+	}
+	if c.NoteMarker == "" {
+		return nil, fmt.Errorf("%v: note_marker must not be empty", name)
+	}
+	for _, style := range []string{c.Style, c.DarkStyle} {
+		if style != "" && !validStyle(style) {
+			return nil, fmt.Errorf("%v: no chroma style named %q", name, style)
+		}
+	}
+	if err := validMarkdownExtensions(c.MarkdownExtensions); err != nil {
+		return nil, fmt.Errorf("%v: %v", name, err)
+	}
+	c.BaseURL = strings.TrimSuffix(c.BaseURL, "/")
+	for _, dir := range []*string{&c.Src, &c.Out} {
+		if *dir == "" {
+			return nil, fmt.Errorf("%v: src and out must not be empty", name)
+		}
+		if !filepath.IsAbs(*dir) {
+			*dir = filepath.Join(filepath.Dir(name), *dir)
+		}
+	}
+	for _, dir := range []*string{&c.Upstream, &c.Templates} {
+		if *dir != "" && !filepath.IsAbs(*dir) {
+			*dir = filepath.Join(filepath.Dir(name), *dir)
+		}
+	}
+	return c, nil
+}
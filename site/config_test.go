@@ -0,0 +1,20 @@
+package site
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultConfigHasNoTemplates(t *testing.T) {
+	// a library caller's working directory is none of its business
+	if dir := DefaultConfig().Templates; dir != "" {
+		t.Errorf("DefaultConfig().Templates = %q, want none", dir)
+	}
+	c, err := LoadConfig(filepath.Join(t.TempDir(), ConfigFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(c.Templates) != "templates" || !filepath.IsAbs(c.Templates) {
+		t.Errorf("LoadConfig(missing file).Templates = %q, want the templates directory next to it", c.Templates)
+	}
+}
@@ -0,0 +1,102 @@
+package site
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ## Diagnostics
+// A broken artifact shouldn't stop everybody else's notes from being
+// published, so problems are collected while the site is built and reported
+// together at the end, compiler style:
+//
+//     artifacts/foo/foo.jul_1_2020.go:3: CommitDate: cannot parse "July 1"
+
+// a `Diagnostic` is a problem found in a file, optionally at a line
+type Diagnostic struct {
+	File string `json:"file"`
+	// 1-based; 0 if the problem isn't on a particular line
+	Line int `json:"line,omitempty"`
+	// a short name for the kind of problem, e.g. `missing-header`, so tools
+	// can filter on it; may be empty
+	Check string `json:"check,omitempty"`
+	Msg   string `json:"message"`
+}
+
+func (d *Diagnostic) Error() string {
+	if d.Line > 0 {
+		return fmt.Sprintf("%v:%d: %v", d.File, d.Line, d.Msg)
+	}
+	return fmt.Sprintf("%v: %v", d.File, d.Msg)
+}
+
+// a `DiagnosticList` reports several problems as a single error
+type DiagnosticList []*Diagnostic
+
+func (dl DiagnosticList) Error() string {
+	msgs := make([]string, len(dl))
+	for i, d := range dl {
+		msgs[i] = d.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// print every diagnostic, one per line
+func (dl DiagnosticList) Report(w io.Writer) {
+	for _, d := range dl {
+		fmt.Fprintln(w, d.Error())
+	}
+}
+
+// print every diagnostic as a JSON array
+func (dl DiagnosticList) ReportJSON(w io.Writer) error {
+	if dl == nil {
+		dl = DiagnosticList{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(dl)
+}
+
+// a `diagnostics` collects the problems found during a build. It is safe to
+// use from several goroutines.
+type diagnostics struct {
+	mu    sync.Mutex
+	items []*Diagnostic
+}
+
+// record `err`, attributing it to `file` unless it is a `Diagnostic` (or a
+// `DiagnosticList`) already
+func (ds *diagnostics) add(file string, err error) {
+	var list DiagnosticList
+	var d *Diagnostic
+	switch {
+	case errors.As(err, &list):
+	case errors.As(err, &d):
+		list = DiagnosticList{d}
+	default:
+		list = DiagnosticList{{File: file, Msg: err.Error()}}
+	}
+	ds.mu.Lock()
+	ds.items = append(ds.items, list...)
+	ds.mu.Unlock()
+}
+
+// the diagnostics so far, ordered by file and line
+func (ds *diagnostics) list() DiagnosticList {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	list := append(DiagnosticList(nil), ds.items...)
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].File != list[j].File {
+			return list[i].File < list[j].File
+		}
+		return list[i].Line < list[j].Line
+	})
+	return list
+}
@@ -0,0 +1,186 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ## Diagrams
+// Control flow is easier to draw than to describe, so notes can hold
+// ```` ```dot ```` (or `graphviz`) and ```` ```mermaid ```` fenced blocks. They
+// are drawn into inline SVG while the site is built, by Graphviz's `dot` and
+// mermaid-cli's `mmdc`, found in `PATH`. Without the tool, or when it fails,
+// the diagram is shown as a code block instead and the build says why.
+
+// a `diagramTool` draws one kind of diagram
+type diagramTool struct {
+	// the binary, looked up in `PATH`
+	binary string
+	// what to install to get it
+	install string
+	// the arguments to draw the diagram in the file `in` as SVG, into the
+	// file `out`
+	args func(in, out string) []string
+}
+
+// the tools, by the language named after the opening fence
+var diagramTools = map[string]*diagramTool{
+	"dot":      graphviz,
+	"graphviz": graphviz,
+	"mermaid": {"mmdc", "mermaid-cli (npm install -g @mermaid-js/mermaid-cli)", func(in, out string) []string {
+		return []string{"--quiet", "-i", in, "-o", out}
+	}},
+}
+
+var graphviz = &diagramTool{"dot", "Graphviz", func(in, out string) []string {
+	return []string{"-Tsvg", "-o", out, in}
+}}
+
+// a `diagrams` draws the diagrams of a build, each one once. Pages are
+// generated concurrently, and so are their diagrams.
+type diagrams struct {
+	log *log.Logger
+
+	mu sync.Mutex
+	// SVG by language and source
+	drawn map[string][]byte
+	// the diagrams being drawn, closed when they are
+	drawing map[string]chan struct{}
+	// the binaries already reported missing
+	missing map[string]bool
+	// the files with a diagram shown as code instead
+	undrawn map[string]bool
+}
+
+func newDiagrams(logger *log.Logger) *diagrams {
+	return &diagrams{log: logger, drawn: make(map[string][]byte), drawing: make(map[string]chan struct{}), missing: make(map[string]bool), undrawn: make(map[string]bool)}
+}
+
+// where each diagram tool is installed, or nothing for those that aren't, so
+// installing one rebuilds the pages it can now draw diagrams on
+func diagramToolPaths() []byte {
+	var binaries []string
+	for _, tool := range diagramTools {
+		binaries = append(binaries, tool.binary)
+	}
+	sort.Strings(binaries)
+	out := new(bytes.Buffer)
+	for i, binary := range binaries {
+		if i > 0 && binary == binaries[i-1] {
+			continue
+		}
+		found, _ := exec.LookPath(binary)
+		fmt.Fprintf(out, "%v=%v\n", binary, found)
+	}
+	return out.Bytes()
+}
+
+// whether a diagram in `file` was shown as code because it couldn't be drawn
+func (d *diagrams) shownAsCode(file string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.undrawn[file]
+}
+
+// whether `lang` names a kind of diagram
+func isDiagram(lang string) bool {
+	_, ok := diagramTools[strings.ToLower(lang)]
+	return ok
+}
+
+// the diagram `source` in the language `lang` as inline SVG, or nil, after
+// logging why, if it can't be drawn. `file` is where the diagram is, for the
+// log.
+func (d *diagrams) draw(file, lang string, source []byte) []byte {
+	tool := diagramTools[strings.ToLower(lang)]
+	key := hashOf([]byte(tool.binary), source)
+
+	d.mu.Lock()
+	for {
+		if svg, ok := d.drawn[key]; ok {
+			if svg == nil {
+				d.undrawn[file] = true
+			}
+			d.mu.Unlock()
+			return svg
+		}
+		done, ok := d.drawing[key]
+		if !ok {
+			break
+		}
+		// another page has the same diagram; wait for it
+		d.mu.Unlock()
+		<-done
+		d.mu.Lock()
+	}
+	if _, err := exec.LookPath(tool.binary); err != nil {
+		first := !d.missing[tool.binary]
+		d.missing[tool.binary] = true
+		d.undrawn[file] = true
+		d.mu.Unlock()
+		if first {
+			d.log.Printf("warning: no %v in PATH, so %v diagrams are shown as code; install %v to draw them", tool.binary, lang, tool.install)
+		}
+		return nil
+	}
+	done := make(chan struct{})
+	d.drawing[key] = done
+	d.mu.Unlock()
+
+	// the tool runs without the lock, so other diagrams are drawn meanwhile
+	svg, err := tool.draw(source)
+	if err != nil {
+		d.log.Printf("warning: %v: %v diagram shown as code: %v", file, lang, err)
+		svg = nil
+	}
+	d.mu.Lock()
+	d.drawn[key] = svg
+	if svg == nil {
+		d.undrawn[file] = true
+	}
+	delete(d.drawing, key)
+	d.mu.Unlock()
+	close(done)
+	return svg
+}
+
+// run the tool on `source` and return the SVG it drew, without the XML
+// declaration and doctype so it can be inlined
+func (t *diagramTool) draw(source []byte) ([]byte, error) {
+	dir, err := ioutil.TempDir("", "lazylit-diagram")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	in, out := filepath.Join(dir, "in.mmd"), filepath.Join(dir, "out.svg")
+
+	if err := ioutil.WriteFile(in, source, 0644); err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(t.binary, t.args(in, out)...)
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%v: %v", t.binary, msg)
+		}
+		return nil, fmt.Errorf("%v: %v", t.binary, err)
+	}
+	svg, err := ioutil.ReadFile(out)
+	if err != nil {
+		return nil, err
+	}
+	start := bytes.Index(svg, []byte("<svg"))
+	if start < 0 {
+		return nil, fmt.Errorf("%v drew no SVG", t.binary)
+	}
+	return bytes.TrimSpace(svg[start:]), nil
+}
@@ -0,0 +1,52 @@
+package site
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestDiagramsDrawnOnceToolIsInstalled(t *testing.T) {
+	bin, out := t.TempDir(), t.TempDir()
+	t.Setenv("PATH", bin)
+	src := fstest.MapFS{
+		"tool/tool.jan_1_2020.go": {Data: []byte(testHeaders + "\n// ```dot\n// digraph { a -> b }\n// ```\nfunc a() {}\n")},
+	}
+	page := filepath.Join(out, "tool", "tool.jan_1_2020.html")
+	build := func() (string, string) {
+		t.Helper()
+		logged := new(bytes.Buffer)
+		if _, err := Build(context.Background(), Options{Src: src, SrcDir: "artifacts", OutDir: out, Log: log.New(logged, "", 0)}); err != nil {
+			t.Fatal(err)
+		}
+		html, err := ioutil.ReadFile(page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(html), logged.String()
+	}
+
+	// every build says why the diagram is shown as code
+	for i := 0; i < 2; i++ {
+		html, logged := build()
+		if !strings.Contains(logged, "no dot in PATH") {
+			t.Errorf("build %v: no warning about dot in %q", i+1, logged)
+		}
+		if strings.Contains(html, `class="diagram"`) {
+			t.Errorf("build %v: drew a diagram without dot", i+1)
+		}
+	}
+
+	dot := "#!/bin/sh\n# -Tsvg -o out in\necho '<svg>drawn</svg>' > \"$3\"\n"
+	if err := ioutil.WriteFile(filepath.Join(bin, "dot"), []byte(dot), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if html, _ := build(); !strings.Contains(html, `<div class="diagram"><svg>drawn</svg></div>`) {
+		t.Errorf("diagram not drawn once dot is installed:\n%v", html)
+	}
+}
@@ -0,0 +1,397 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+
+	"github.com/alecthomas/chroma"
+	"github.com/alecthomas/chroma/lexers"
+)
+
+// ## Revision diffs
+// Every pair of adjacent snapshots of an artifact gets a page showing what
+// changed between them: the notes that were added, edited or removed, and a
+// side-by-side view of the code. `WriteDiff` renders the page for any other
+// pair on demand.
+
+// a `Diff` compares two snapshots of the same artifact
+type Diff struct {
+	Older, Newer Snapshot
+}
+
+// the slash-separated path of the diff's page within the output directory
+func (d Diff) Destination() string {
+	return path.Join(d.Newer.ArtifactName, d.Older.PageName()+".."+d.Newer.PageName()+".html")
+}
+
+// the name of the snapshot's page, without the directory and `.html`, e.g.
+// `tiddlylisp.may_16_2020`
+func (a Snapshot) PageName() string {
+	return strings.TrimSuffix(path.Base(a.Destination()), ".html")
+}
+
+// the diffs between adjacent snapshots, newest first
+func (a Artifact) Diffs() []Diff {
+	var diffs []Diff
+	for i := 0; i+1 < len(a.Snapshots); i++ {
+		diffs = append(diffs, Diff{Older: a.Snapshots[i+1], Newer: a.Snapshots[i]})
+	}
+	return diffs
+}
+
+// a `NoteChange` is a note that differs between two snapshots
+type NoteChange struct {
+	// "added", "edited" or "removed"
+	Kind             string
+	OldHTML, NewHTML string
+}
+
+// a `DiffRow` is a line of the side-by-side code view. `Kind` is "same",
+// "removed", "added", "changed" or "skip", for a run of unchanged lines
+// that isn't shown.
+type DiffRow struct {
+	Kind             string
+	OldHTML, NewHTML string
+}
+
+// a `DiffTemplateData` is per-diff
+type DiffTemplateData struct {
+	Title string
+	Diff  Diff
+	Notes []NoteChange
+	Rows  []DiffRow
+	Page
+}
+
+// how many unchanged lines are shown around each change
+const diffContext = 3
+
+// render the page of `d` into the output directory, returning its path on
+// disk
+func (s *Site) WriteDiff(d Diff) (string, error) {
+	if err := ensureDirectory(s.b.outPath(d.Newer.ArtifactName)); err != nil {
+		return "", err
+	}
+	if err := s.b.generateDiff(d, emptyManifest(s.b.outDir), emptyManifest(s.b.outDir)); err != nil {
+		return "", err
+	}
+	return s.b.outPath(d.Destination()), nil
+}
+
+// render the page of `d`, unless `prev` says it is up to date; either way
+// the page is recorded in `next`
+func (b *builder) generateDiff(d Diff, prev, next *Manifest) error {
+	older, oldList, err := b.load(d.Older)
+	if err != nil {
+		return err
+	}
+	newer, newList, err := b.load(d.Newer)
+	if err != nil {
+		return err
+	}
+	hash := hashOf(older, newer, []byte(d.Older.CommitDateString), []byte(d.Newer.CommitDateString))
+	if prev.upToDate(d.Destination(), hash) {
+		next.record(d.Destination(), hash)
+		return nil
+	}
+
+	oldSections, newSections := sectionSlice(oldList), sectionSlice(newList)
+	rows, err := diffCode(d, oldSections, newSections)
+	if err != nil {
+		return err
+	}
+
+	b.log.Println("gocco: ", d.Older.DocFileName, "..", d.Newer.DocFileName, " -> ", b.outPath(d.Destination()))
+	err = b.renderToFile(diffTemplate, d.Destination(), DiffTemplateData{
+		Title: path.Base(d.Newer.SourceFileName),
+		Diff:  d,
+		Notes: b.diffNotes(d.Newer.DocFileName, oldSections, newSections),
+		Rows:  rows,
+		Page:  b.page("../"),
+	})
+	if err != nil {
+		return err
+	}
+	if b.diagrams.shownAsCode(d.Newer.DocFileName) {
+		hash = ""
+	}
+	next.record(d.Destination(), hash)
+	return nil
+}
+
+// the notes of `file` that were added, edited or removed. Notes that
+// changed in the same place are paired up as edits.
+func (b *builder) diffNotes(file string, oldSections, newSections []*Section) []NoteChange {
+	notes := func(sections []*Section) []string {
+		var out []string
+		for _, sec := range sections {
+			if text := strings.TrimSpace(string(sec.docsText)); text != "" {
+				out = append(out, text)
+			}
+		}
+		return out
+	}
+	ids := newPageIDs()
+	render := func(text string) string {
+		return string(b.markdown(file, []byte(text), ids))
+	}
+
+	var changes []NoteChange
+	oldNotes, newNotes := notes(oldSections), notes(newSections)
+	for _, h := range hunks(oldNotes, newNotes, diffLines(oldNotes, newNotes)) {
+		for i := 0; i < len(h.removed) || i < len(h.added); i++ {
+			switch {
+			case i < len(h.removed) && i < len(h.added):
+				changes = append(changes, NoteChange{"edited", render(h.removed[i]), render(h.added[i])})
+			case i < len(h.removed):
+				changes = append(changes, NoteChange{"removed", render(h.removed[i]), ""})
+			default:
+				changes = append(changes, NoteChange{"added", "", render(h.added[i])})
+			}
+		}
+	}
+	return changes
+}
+
+// the side-by-side rows for the code of two snapshots, leaving out
+// unchanged lines far from any change
+func diffCode(d Diff, oldSections, newSections []*Section) ([]DiffRow, error) {
+	oldLines, oldHTML, err := codeLines(d.Older.language, oldSections)
+	if err != nil {
+		return nil, err
+	}
+	newLines, newHTML, err := codeLines(d.Newer.language, newSections)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []DiffRow
+	ops := diffLines(oldLines, newLines)
+	for i := 0; i < len(ops); {
+		if ops[i].kind != opSame {
+			// removals come before additions, so pair them up as changes
+			j := i
+			for j < len(ops) && ops[j].kind == opRemoved {
+				j++
+			}
+			k := j
+			for k < len(ops) && ops[k].kind == opAdded {
+				k++
+			}
+			removed, added := ops[i:j], ops[j:k]
+			for n := 0; n < len(removed) || n < len(added); n++ {
+				switch {
+				case n < len(removed) && n < len(added):
+					rows = append(rows, DiffRow{"changed", oldHTML[removed[n].old], newHTML[added[n].new]})
+				case n < len(removed):
+					rows = append(rows, DiffRow{"removed", oldHTML[removed[n].old], ""})
+				default:
+					rows = append(rows, DiffRow{"added", "", newHTML[added[n].new]})
+				}
+			}
+			i = k
+			continue
+		}
+
+		j := i
+		for j < len(ops) && ops[j].kind == opSame {
+			j++
+		}
+		// show the context after the previous change and before the next
+		head, tail := diffContext, diffContext
+		if i == 0 {
+			head = 0
+		}
+		if j == len(ops) {
+			tail = 0
+		}
+		if j-i <= head+tail {
+			head, tail = j-i, 0
+		}
+		for _, op := range ops[i : i+head] {
+			rows = append(rows, DiffRow{"same", oldHTML[op.old], newHTML[op.new]})
+		}
+		if j-i > head+tail {
+			rows = append(rows, DiffRow{Kind: "skip"})
+		}
+		for _, op := range ops[j-tail : j] {
+			rows = append(rows, DiffRow{"same", oldHTML[op.old], newHTML[op.new]})
+		}
+		i = j
+	}
+	return rows, nil
+}
+
+// the code lines of `sections`, plain and highlighted
+func codeLines(language *Language, sections []*Section) ([]string, []string, error) {
+	code := new(bytes.Buffer)
+	for _, sec := range sections {
+		code.Write(sec.codeText)
+	}
+	text := strings.TrimSuffix(code.String(), "\n")
+	if text == "" {
+		return nil, nil, nil
+	}
+	lines := strings.Split(text, "\n")
+	highlighted, err := highlightLines(language, lines)
+	if err != nil {
+		return nil, nil, err
+	}
+	return lines, highlighted, nil
+}
+
+// the HTML of each of `lines`, highlighted as a whole
+func highlightLines(language *Language, lines []string) ([]string, error) {
+	iterator, err := chroma.Coalesce(lexers.Get(language.name)).Tokenise(nil, strings.Join(lines, "\n")+"\n")
+	if err != nil {
+		return nil, fmt.Errorf("Error tokenizing: %v", err)
+	}
+	highlighted := make([]string, len(lines))
+	for i, tokens := range chroma.SplitTokensIntoLines(iterator.Tokens()) {
+		if i == len(lines) {
+			break
+		}
+		buf := new(strings.Builder)
+		for _, t := range tokens {
+			value := template.HTMLEscapeString(strings.TrimSuffix(t.Value, "\n"))
+			if class := tokenClass(t.Type); class != "" && value != "" {
+				fmt.Fprintf(buf, `<span class="%v">%v</span>`, class, value)
+			} else {
+				buf.WriteString(value)
+			}
+		}
+		highlighted[i] = buf.String()
+	}
+	return highlighted, nil
+}
+
+// the CSS class chroma gives tokens of type `t`
+func tokenClass(t chroma.TokenType) string {
+	for _, tt := range []chroma.TokenType{t, t.SubCategory(), t.Category()} {
+		if class, ok := chroma.StandardTypes[tt]; ok {
+			return class
+		}
+	}
+	return ""
+}
+
+// ### Line diffs
+// A longest common subsequence of the lines, after trimming the common start
+// and end. Snapshots are hand-sized files, so the quadratic table is fine;
+// if it gets too big, the middle is simply shown as replaced.
+
+// the kinds of `diffOp`
+const (
+	opSame = iota
+	opRemoved
+	opAdded
+)
+
+// a `diffOp` keeps, removes or adds a line; `old` and `new` are line indexes
+type diffOp struct {
+	kind     int
+	old, new int
+}
+
+// the largest table `diffLines` builds
+const maxDiffCells = 4 << 20
+
+// the operations that turn `a` into `b`, with removals before additions
+func diffLines(a, b []string) []diffOp {
+	var ops []diffOp
+	start := 0
+	for start < len(a) && start < len(b) && a[start] == b[start] {
+		ops = append(ops, diffOp{opSame, start, start})
+		start++
+	}
+	endA, endB := len(a), len(b)
+	for endA > start && endB > start && a[endA-1] == b[endB-1] {
+		endA--
+		endB--
+	}
+
+	midA, midB := a[start:endA], b[start:endB]
+	if len(midA)*len(midB) > maxDiffCells {
+		for i := range midA {
+			ops = append(ops, diffOp{opRemoved, start + i, -1})
+		}
+		for j := range midB {
+			ops = append(ops, diffOp{opAdded, -1, start + j})
+		}
+	} else {
+		// lcs[i][j] is the length of the LCS of midA[i:] and midB[j:]
+		lcs := make([][]int, len(midA)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(midB)+1)
+		}
+		for i := len(midA) - 1; i >= 0; i-- {
+			for j := len(midB) - 1; j >= 0; j-- {
+				if midA[i] == midB[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else if lcs[i+1][j] >= lcs[i][j+1] {
+					lcs[i][j] = lcs[i+1][j]
+				} else {
+					lcs[i][j] = lcs[i][j+1]
+				}
+			}
+		}
+		i, j := 0, 0
+		var added []diffOp
+		flush := func() {
+			ops = append(ops, added...)
+			added = nil
+		}
+		for i < len(midA) || j < len(midB) {
+			switch {
+			case i < len(midA) && j < len(midB) && midA[i] == midB[j]:
+				flush()
+				ops = append(ops, diffOp{opSame, start + i, start + j})
+				i++
+				j++
+			case j == len(midB) || (i < len(midA) && lcs[i+1][j] >= lcs[i][j+1]):
+				ops = append(ops, diffOp{opRemoved, start + i, -1})
+				i++
+			default:
+				added = append(added, diffOp{opAdded, -1, start + j})
+				j++
+			}
+		}
+		flush()
+	}
+
+	for k := 0; endA+k < len(a); k++ {
+		ops = append(ops, diffOp{opSame, endA + k, endB + k})
+	}
+	return ops
+}
+
+// a `hunk` is a run of removed and added lines between unchanged ones
+type hunk struct {
+	removed, added []string
+}
+
+// the hunks of a diff of `a` and `b`
+func hunks(a, b []string, ops []diffOp) []hunk {
+	var out []hunk
+	var h hunk
+	for _, op := range ops {
+		switch op.kind {
+		case opSame:
+			if len(h.removed)+len(h.added) > 0 {
+				out = append(out, h)
+				h = hunk{}
+			}
+		case opRemoved:
+			h.removed = append(h.removed, a[op.old])
+		case opAdded:
+			h.added = append(h.added, b[op.new])
+		}
+	}
+	if len(h.removed)+len(h.added) > 0 {
+		out = append(out, h)
+	}
+	return out
+}
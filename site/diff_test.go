@@ -0,0 +1,135 @@
+package site
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// the ops as a line each: `=` for kept lines, `-` for removed and `+` for
+// added ones, followed by the line
+func showOps(a, b []string, ops []diffOp) string {
+	var out []string
+	for _, op := range ops {
+		switch op.kind {
+		case opSame:
+			out = append(out, "="+a[op.old])
+		case opRemoved:
+			out = append(out, "-"+a[op.old])
+		case opAdded:
+			out = append(out, "+"+b[op.new])
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want string
+	}{
+		{"", "", ""},
+		{"a b c", "a b c", "=a =b =c"},
+		{"", "a b", "+a +b"},
+		{"a b", "", "-a -b"},
+		{"a b c", "a c", "=a -b =c"},
+		{"a c", "a b c", "=a +b =c"},
+		{"a b c", "a x c", "=a -b +x =c"},
+		// removals come before the additions they make way for
+		{"a b c d", "a x y d", "=a -b -c +x +y =d"},
+		{"x a b", "a b y", "-x =a =b +y"},
+		{"a b a b", "b a b a", "-a =b =a =b +a"},
+	}
+	for _, tt := range tests {
+		a, b := strings.Fields(tt.a), strings.Fields(tt.b)
+		if got := showOps(a, b, diffLines(a, b)); got != tt.want {
+			t.Errorf("diffLines(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestDiffLinesTooBig(t *testing.T) {
+	// past `maxDiffCells`, the changed middle is all removed, then all added
+	var a, b []string
+	for i := 0; i < 2100; i++ {
+		a = append(a, fmt.Sprint("a", i))
+		b = append(b, fmt.Sprint("b", i))
+	}
+	a = append([]string{"start"}, append(a, "end")...)
+	b = append([]string{"start"}, append(b, "end")...)
+	ops := diffLines(a, b)
+	if len(ops) != 4202 {
+		t.Fatalf("got %v ops, want 4202", len(ops))
+	}
+	for i, op := range ops {
+		var want int
+		switch {
+		case i == 0 || i == len(ops)-1:
+			want = opSame
+		case i <= 2100:
+			want = opRemoved
+		default:
+			want = opAdded
+		}
+		if op.kind != want {
+			t.Fatalf("op %v is %v, want %v", i, op.kind, want)
+		}
+	}
+}
+
+func TestHunks(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want []hunk
+	}{
+		{"a b c", "a b c", nil},
+		{"a b c", "a x c", []hunk{{[]string{"b"}, []string{"x"}}}},
+		{"a b c d e", "x b c e y", []hunk{
+			{[]string{"a"}, []string{"x"}},
+			{[]string{"d"}, nil},
+			{nil, []string{"y"}},
+		}},
+	}
+	for _, tt := range tests {
+		a, b := strings.Fields(tt.a), strings.Fields(tt.b)
+		got := hunks(a, b, diffLines(a, b))
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("hunks(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestTwoSnapshotsLinkToTheirDiff(t *testing.T) {
+	out := t.TempDir()
+	src := fstest.MapFS{
+		"tool/tool.jan_1_2020.go": {Data: []byte(testHeaders + "\n// a note\nfunc a() {}\n")},
+		"tool/tool.feb_1_2020.go": {Data: []byte(strings.Replace(testHeaders, "Jan 1 2020", "Feb 1 2020", 1) + "\n// a note\nfunc a() { return }\n")},
+	}
+	s, err := Build(context.Background(), Options{Src: src, SrcDir: "artifacts", OutDir: out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffs := s.Artifacts[0].Diffs()
+	if len(diffs) != 1 {
+		t.Fatalf("got %v diffs, want 1", len(diffs))
+	}
+	link := `href="` + path.Base(diffs[0].Destination()) + `"`
+	for _, a := range s.Artifacts[0].Snapshots {
+		page, err := ioutil.ReadFile(filepath.Join(out, filepath.FromSlash(a.Destination())))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(page), link) {
+			t.Errorf("%v doesn't link to its diff with %v", a.Destination(), link)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(out, filepath.FromSlash(diffs[0].Destination()))); err != nil {
+		t.Error(err)
+	}
+}
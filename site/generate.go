@@ -0,0 +1,513 @@
+package site
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ## Types
+// Due to Go's statically typed nature, what is passed around in object
+// literals in Docco, requires various structures
+
+// A `Section` captures a piece of documentation and code
+// Every time interleaving code is found between two comments
+// a new `Section` is created.
+type Section struct {
+	docsText []byte
+	codeText []byte
+	DocsHTML []byte
+	CodeHTML []byte
+	// the highlighted code, line by line
+	Lines []Line
+	// where the notes and the code start in their files, 0-based
+	docsLine, codeLine int
+	// the line number of each line of code in the upstream file
+	sourceLines []int
+}
+
+// a `Line` is a highlighted line of code
+type Line struct {
+	// the line number in the upstream file at `Commit`, or 0 if the line
+	// isn't part of it, like the blank line after the headers
+	Number int
+	HTML   string
+}
+
+// a `TemplateSection` is a section that can be passed
+// to Go's templating system, which expects strings.
+type TemplateSection struct {
+	DocsHTML string
+	CodeHTML string
+	// The code line by line, to number the lines
+	Lines []Line
+	// The `Index` field is used to create anchors to sections
+	Index int
+}
+
+// a `TemplateData` is per-file
+type TemplateData struct {
+	// Title of the HTML output
+	Title string
+	// The Sections making up this file
+	Sections []*TemplateSection
+	// List of other revisions for same artifact.
+	OtherRevisions []Snapshot
+	// Only generate the TOC if there is another revision or a diff to
+	// link to. Go's templating system does not allow expressions in the
+	// template, so calculate it outside
+	Multiple bool
+	Snapshot *Snapshot
+	// The diffs between adjacent revisions of the artifact
+	Diffs []Diff
+	// How far the snapshot is behind upstream; nil without an upstream
+	Staleness *Staleness
+	// Whether the code is numbered as in the upstream file: not in "all"
+	// mode, where that file's own comments became notes
+	Numbered bool
+	Page
+}
+
+// an `IndexTemplateData` is per-artifact
+type IndexTemplateData struct {
+	Artifact
+	Page
+}
+
+// an `AboutTemplateData` is for the front page
+type AboutTemplateData struct {
+	// sorted, like `Artifacts`
+	ArtifactNames []string
+	Artifacts     []ArtifactSummary
+	// every artifact's tags, sorted
+	Tags []string
+	Page
+}
+
+// an `ArtifactSummary` is an artifact's row on the front page
+type ArtifactSummary struct {
+	Name string
+	// the newest snapshot, for its source file and date
+	Latest    Snapshot
+	Revisions int
+	// everyone who wrote notes for the artifact, sorted
+	Authors []string
+	// from the newest snapshot that has them
+	Summary string
+	Tags    []string
+}
+
+// the front page row of `a`
+func summarize(a Artifact) ArtifactSummary {
+	s := ArtifactSummary{Name: a.Name, Latest: a.Snapshots[0], Revisions: len(a.Snapshots)}
+	authors := make(map[string]bool)
+	for _, snap := range a.Snapshots {
+		if snap.DocAuthor != "" && !authors[snap.DocAuthor] {
+			authors[snap.DocAuthor] = true
+			s.Authors = append(s.Authors, snap.DocAuthor)
+		}
+		if s.Summary == "" {
+			s.Summary = snap.Summary
+		}
+		if s.Tags == nil {
+			s.Tags = snap.Tags
+		}
+	}
+	sort.Strings(s.Authors)
+	return s
+}
+
+// Wrap the code in these
+const highlightStart = "<div class=\"highlight\"><pre>"
+const highlightEnd = "</pre></div>"
+
+// ## Main documentation generation functions
+
+// Generate the documentation for a single source file
+// by splitting it into sections, highlighting each section
+// and putting it together.
+// The WaitGroup is used to signal we are done, so that the main
+// goroutine waits for all the sub goroutines. Problems are reported rather
+// than stopping the build. Pages that `prev` says are up to date are
+// skipped; either way the page is recorded in `next`.
+func (b *builder) generateDocumentation(a Snapshot, otherRevs []Snapshot, diffs []Diff, wg *sync.WaitGroup, prev, next *Manifest) {
+	defer wg.Done()
+	code, sections, err := b.load(a)
+	if err != nil {
+		b.report.add(a.DocFileName, err)
+		return
+	}
+	hash := snapshotHash(code, otherRevs)
+	var staleness *Staleness
+	if b.upstream != nil {
+		// the page changes with upstream, not just with the snapshot
+		if staleness, err = b.upstream.staleness(a, sectionSlice(sections)); err != nil {
+			b.log.Printf("%v: can't compare to upstream: %v", a.DocFileName, err)
+		} else {
+			hash = hashOf([]byte(hash), []byte(fmt.Sprint(*staleness)))
+		}
+	}
+	if prev.upToDate(a.Destination(), hash) {
+		next.record(a.Destination(), hash)
+		return
+	}
+	if err := b.highlight(a, sections); err != nil {
+		b.report.add(a.DocFileName, err)
+		return
+	}
+	if err := b.generateHTML(a, otherRevs, diffs, staleness, sections); err != nil {
+		b.report.add(a.DocFileName, err)
+		return
+	}
+	if b.diagrams.shownAsCode(a.DocFileName) {
+		// never up to date, so the warning is repeated until the diagram
+		// can be drawn
+		hash = ""
+	}
+	next.record(a.Destination(), hash)
+}
+
+// Parse splits code into `Section`s. With a `marker`, only comments carrying
+// it (e.g. `//>`) become documentation, and every other comment stays in the
+// code.
+func parse(language *Language, code []byte, startLine int, marker string) *list.List {
+	lines := bytes.Split(code, []byte("\n"))
+	sections := new(list.List)
+	sections.Init()
+
+	matcher := language.commentMatcher
+	if marker != "" {
+		matcher = language.noteMatcher
+	}
+
+	var hasCode bool
+	var codeText = new(bytes.Buffer)
+	var docsText = new(bytes.Buffer)
+	docsLine, codeLine := startLine, -1
+	// numbering the lines of the upstream file: notes aren't part of it
+	var sourceLines []int
+	n := 0
+
+	// save a new section, the next one starting on line `next`
+	save := func(docs, code []byte, next int) {
+		// deep copy the slices since slices always refer to the same storage
+		// by default
+		docsCopy, codeCopy := make([]byte, len(docs)), make([]byte, len(code))
+		copy(docsCopy, docs)
+		copy(codeCopy, code)
+		if codeLine < 0 {
+			codeLine = next
+		}
+		sections.PushBack(&Section{docsCopy, codeCopy, nil, nil, nil, docsLine, codeLine, sourceLines})
+		docsLine, codeLine, sourceLines = next, -1, nil
+	}
+
+	// a `#!` line is code, even though it looks like a `#` comment
+	shebangLine := startLine
+	for shebangLine < len(lines) && len(bytes.TrimSpace(lines[shebangLine])) == 0 {
+		shebangLine++
+	}
+
+	// the newline ending the file doesn't start another line
+	end := len(lines)
+	if end > startLine && len(lines[end-1]) == 0 {
+		end--
+	}
+	for i := startLine; i < end; i++ {
+		line := lines[i]
+		isShebang := i == shebangLine && bytes.HasPrefix(line, []byte("#!"))
+		// a block comment on its own lines is documentation too
+		if end, text, ok := language.blockAt(lines, i, marker); ok && !isShebang {
+			if hasCode {
+				save(docsText.Bytes(), codeText.Bytes(), i)
+				hasCode = false
+				codeText.Reset()
+				docsText.Reset()
+			}
+			docsText.Write(text)
+			i = end
+			continue
+		}
+		// if the line is a comment
+		if matcher != nil && matcher.Match(line) && !isShebang {
+			// but there was previous code
+			if hasCode {
+				// we need to save the existing documentation and text
+				// as a section and start a new section, since the code
+				// is shown next to the documentation that precedes it
+				save(docsText.Bytes(), codeText.Bytes(), i)
+				hasCode = false
+				codeText.Reset()
+				docsText.Reset()
+			}
+			docsText.Write(matcher.ReplaceAll(line, nil))
+			docsText.WriteString("\n")
+		} else {
+			if !hasCode {
+				codeLine = i
+			}
+			hasCode = true
+			if marker == "" || i == startLine && len(bytes.TrimSpace(line)) == 0 {
+				// the blank line separating the headers from the source, or
+				// any line in "all" mode, where there is no telling the
+				// upstream file's comments from notes
+				sourceLines = append(sourceLines, 0)
+			} else {
+				n++
+				sourceLines = append(sourceLines, n)
+			}
+			codeText.Write(line)
+			codeText.WriteString("\n")
+		}
+	}
+	// save any remaining parts of the source file
+	save(docsText.Bytes(), codeText.Bytes(), len(lines))
+	return sections
+}
+
+// `highlight` runs the code of all the sections through chroma at once, so
+// the lexer sees the file as it is, then hands each `Section` back its lines
+// and renders its documentation
+func (b *builder) highlight(a Snapshot, sections *list.List) error {
+	var lines []string
+	for e := sections.Front(); e != nil; e = e.Next() {
+		if code := e.Value.(*Section).codeText; len(code) > 0 {
+			lines = append(lines, strings.Split(strings.TrimSuffix(string(code), "\n"), "\n")...)
+		}
+	}
+	highlighted, err := highlightLines(a.language, lines)
+	if err != nil {
+		return err
+	}
+	ids := newPageIDs()
+	for e := sections.Front(); e != nil; e = e.Next() {
+		sec := e.Value.(*Section)
+		n := bytes.Count(sec.codeText, []byte("\n"))
+		sec.Lines = make([]Line, n)
+		for i := range sec.Lines {
+			sec.Lines[i] = Line{sec.sourceLines[i], highlighted[i]}
+		}
+		sec.CodeHTML = []byte(highlightStart + strings.Join(highlighted[:n], "\n") + highlightEnd)
+		sec.DocsHTML = b.markdown(a.DocFileName, sec.docsText, ids)
+		highlighted = highlighted[n:]
+	}
+	return nil
+}
+
+// read the snapshot `a` and split it into `Section`s. Also returns what the
+// sections were made from, to tell whether its pages are up to date.
+func (b *builder) load(a Snapshot) ([]byte, *list.List, error) {
+	code, err := fs.ReadFile(b.src, a.path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if a.notesPath == "" {
+		return code, parse(a.language, code, a.FirstNonHeaderLine, b.marker(a)), nil
+	}
+	notes, err := fs.ReadFile(b.src, a.notesPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	// bad line ranges were reported when the snapshot was scanned
+	sections, _ := splitSidecar(a.DocFileName, bytes.Split(notes, []byte("\n")), a.FirstNonHeaderLine, code)
+	return []byte(hashOf(notes, code)), sections, nil
+}
+
+// the highlighted `Section`s of the snapshot `a`, as they appear on its page
+func (s *Site) Sections(a Snapshot) ([]*Section, error) {
+	_, sections, err := s.b.load(a)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.b.highlight(a, sections); err != nil {
+		return nil, err
+	}
+	return sectionSlice(sections), nil
+}
+
+// the `Section`s in `sections`, in order
+func sectionSlice(sections *list.List) []*Section {
+	out := make([]*Section, 0, sections.Len())
+	for e := sections.Front(); e != nil; e = e.Next() {
+		out = append(out, e.Value.(*Section))
+	}
+	return out
+}
+
+// render the final HTML
+func (b *builder) generateHTML(a Snapshot, otherRevs []Snapshot, diffs []Diff, staleness *Staleness, sections *list.List) error {
+	// convert every `Section` into corresponding `TemplateSection`
+	sectionsArray := make([]*TemplateSection, sections.Len())
+	for e, i := sections.Front(), 0; e != nil; e, i = e.Next(), i+1 {
+		var sec = e.Value.(*Section)
+		docsBuf := bytes.NewBuffer(sec.DocsHTML)
+		codeBuf := bytes.NewBuffer(sec.CodeHTML)
+		sectionsArray[i] = &TemplateSection{docsBuf.String(), codeBuf.String(), sec.Lines, i + 1}
+	}
+	// run through the Go template
+	html, err := b.templates.render(pageTemplate, TemplateData{
+		Title:          path.Base(a.SourceFileName),
+		Sections:       sectionsArray,
+		OtherRevisions: otherRevs,
+		Multiple:       len(otherRevs) > 0 || len(diffs) > 0,
+		Snapshot:       &a,
+		Diffs:          diffs,
+		Staleness:      staleness,
+		Numbered:       a.notesPath != "" || b.marker(a) != "",
+		Page:           b.page("../"),
+	})
+	if err != nil {
+		return err
+	}
+	dest := b.outPath(a.Destination())
+	b.log.Println("gocco: ", a.DocFileName, " -> ", dest)
+	return writeIfChanged(dest, html)
+}
+
+// make sure the directory `name` exists
+func ensureDirectory(name string) error {
+	return os.MkdirAll(name, 0755)
+}
+
+func (b *builder) generateIndexes(artifacts []Artifact, next *Manifest) error {
+	for _, a := range artifacts {
+		if err := ensureDirectory(b.outPath(a.Name)); err != nil {
+			return err
+		}
+		dest := path.Join(a.Name, "index.html")
+		if err := b.renderToFile(artifactTemplate, dest, IndexTemplateData{a, b.page("../")}); err != nil {
+			return err
+		}
+		next.record(dest, "")
+	}
+	return nil
+}
+
+// where the script sorting and filtering the front page's artifacts goes,
+// within the output directory
+const artifactsScriptName = "artifacts.js"
+
+// write the front page, a row per artifact, and its script
+func (b *builder) generateAbout(artifacts []Artifact, next *Manifest) error {
+	data := AboutTemplateData{Page: b.page("")}
+	tags := make(map[string]bool)
+	for _, a := range artifacts {
+		s := summarize(a)
+		data.Artifacts = append(data.Artifacts, s)
+		for _, tag := range s.Tags {
+			if !tags[tag] {
+				tags[tag] = true
+				data.Tags = append(data.Tags, tag)
+			}
+		}
+	}
+	// a stable order keeps rebuilds from producing noisy diffs
+	sort.Slice(data.Artifacts, func(i, j int) bool { return data.Artifacts[i].Name < data.Artifacts[j].Name })
+	sort.Strings(data.Tags)
+	for _, s := range data.Artifacts {
+		data.ArtifactNames = append(data.ArtifactNames, s.Name)
+	}
+
+	next.record("index.html", "")
+	next.record(artifactsScriptName, "")
+	if err := writeIfChanged(b.outPath(artifactsScriptName), []byte(ARTIFACTS_JS)); err != nil {
+		return err
+	}
+	return b.renderToFile(indexTemplate, "index.html", data)
+}
+
+// what every page of the site is given, on a page `root` away from the
+// root of the site
+func (b *builder) page(root string) Page {
+	return Page{Config: b.config, Root: root, Version: Version}
+}
+
+// render the page template `name` with `data` into `dest`, a path within the
+// output directory, leaving the file untouched if the content is the same
+func (b *builder) renderToFile(name, dest string, data interface{}) error {
+	html, err := b.templates.render(name, data)
+	if err != nil {
+		return fmt.Errorf("%v: %v", b.outPath(dest), err)
+	}
+	return writeIfChanged(b.outPath(dest), html)
+}
+
+// write the site-wide files: `.nojekyll`, the about page, the search script
+// and the stylesheet
+func (b *builder) generateSite(artifacts []Artifact, next *Manifest) error {
+	if err := ensureDirectory(b.outDir); err != nil {
+		return err
+	}
+	if err := writeIfChanged(b.outPath(".nojekyll"), nil); err != nil {
+		return fmt.Errorf("Unable to create .nojekyll: %v", err)
+	}
+	next.record(".nojekyll", "")
+	if err := b.generateAbout(artifacts, next); err != nil {
+		return err
+	}
+	css, err := stylesheet(b.config.Style, b.config.DarkStyle)
+	if err != nil {
+		return err
+	}
+	next.record(searchScriptName, "")
+	if err := writeIfChanged(b.outPath(searchScriptName), []byte(SEARCH_JS)); err != nil {
+		return err
+	}
+	if b.config.DarkStyle != "" {
+		next.record(themeScriptName, "")
+		if err := writeIfChanged(b.outPath(themeScriptName), []byte(THEME_JS)); err != nil {
+			return err
+		}
+	}
+	next.record("gocco.css", "")
+	return writeIfChanged(b.outPath("gocco.css"), css)
+}
+
+// write the index, every snapshot page and every diff page of each of
+// `artifacts`, skipping pages that `prev` says are up to date. Stops
+// starting pages once `ctx` is done.
+func (b *builder) generateArtifacts(ctx context.Context, artifacts []Artifact, prev, next *Manifest) error {
+	if err := b.generateIndexes(artifacts, next); err != nil {
+		return err
+	}
+
+	wg := new(sync.WaitGroup)
+	for _, artifact := range artifacts {
+		a := artifact.Snapshots
+		for i, snapshot := range a {
+			if ctx.Err() != nil {
+				break
+			}
+			otherRevs := make([]Snapshot, len(a))
+			copy(otherRevs, a)
+			copy(otherRevs[i:], otherRevs[i+1:])
+			otherRevs = otherRevs[:len(otherRevs)-1]
+			wg.Add(1)
+			go b.generateDocumentation(snapshot, otherRevs, artifact.Diffs(), wg, prev, next)
+		}
+		for _, d := range artifact.Diffs() {
+			if ctx.Err() != nil {
+				break
+			}
+			wg.Add(1)
+			go func(d Diff) {
+				defer wg.Done()
+				if err := b.generateDiff(d, prev, next); err != nil {
+					b.report.add(d.Newer.DocFileName, err)
+				}
+			}(d)
+		}
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return b.generateSearchIndex(ctx, artifacts, prev, next)
+}
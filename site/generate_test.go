@@ -0,0 +1,104 @@
+package site
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// the sections as `docs|code` pairs
+func showSections(sections []*Section) []string {
+	var out []string
+	for _, sec := range sections {
+		out = append(out, fmt.Sprintf("%q|%q", sec.docsText, sec.codeText))
+	}
+	return out
+}
+
+func TestParse(t *testing.T) {
+	ls, err := NewLanguages(DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ext, code, marker string
+		want              []string
+	}{
+		{".go", "// a\nx := 1\n// b\ny := 2\n", "", []string{
+			`"a\n"|"x := 1\n"`,
+			`"b\n"|"y := 2\n"`,
+		}},
+		{".go", "/*\n * a\n *   b\n */\nx := 1\n", "", []string{
+			`"a\n  b\n"|"x := 1\n"`,
+		}},
+		// a block comment followed by code on its line stays code
+		{".go", "/* a */ x := 1\n", "", []string{
+			`""|"/* a */ x := 1\n"`,
+		}},
+		{".py", "def f():\n    \"\"\"\n    Does f.\n    \"\"\"\n", "", []string{
+			`""|"def f():\n"`,
+			`"Does f.\n"|""`,
+		}},
+		{".py", "#!/usr/bin/env python\n# a\nx = 1\n", "", []string{
+			`""|"#!/usr/bin/env python\n"`,
+			`"a\n"|"x = 1\n"`,
+		}},
+		// in "marked" mode, the file's own comments stay in the code
+		{".go", "//> a note\n// a comment\nx := 1\n//> another\ny := 2\n", ">", []string{
+			`"a note\n"|"// a comment\nx := 1\n"`,
+			`"another\n"|"y := 2\n"`,
+		}},
+		{".go", "/*> a note */\n/* a comment */\nx := 1\n", ">", []string{
+			`"a note \n"|"/* a comment */\nx := 1\n"`,
+		}},
+		{".py", "#> a note\n# a comment\n\"\"\"\nA docstring.\n\"\"\"\n", ">", []string{
+			`"a note\n"|"# a comment\n\"\"\"\nA docstring.\n\"\"\"\n"`,
+		}},
+	}
+	for _, tt := range tests {
+		lang, _ := ls.ForExtension(tt.ext)
+		got := showSections(sectionSlice(parse(lang, []byte(tt.code), 0, tt.marker)))
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("parse(%q, %q):\ngot  %q\nwant %q", tt.code, tt.marker, got, tt.want)
+		}
+	}
+}
+
+// the upstream line number of each code line, by section
+func showLineNumbers(sections []*Section) string {
+	var out []string
+	for _, sec := range sections {
+		out = append(out, fmt.Sprint(sec.sourceLines))
+	}
+	return strings.Join(out, " ")
+}
+
+func TestParseLineNumbers(t *testing.T) {
+	ls, err := NewLanguages(DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lang, _ := ls.ForExtension(".go")
+	tests := []struct {
+		code   string
+		first  int
+		marker string
+		want   string
+	}{
+		// in "all" mode there is no telling notes from the upstream file's
+		// comments, so no line is numbered
+		{"// a\nx := 1\n// b\ny := 2\n", 0, "", "[0] [0]"},
+		// the newline ending the file doesn't make a last, empty line
+		{"x := 1\n\n", 0, "", "[0 0]"},
+		// notes aren't part of the upstream file, its own comments are
+		{"//> a note\n// a comment\nx := 1\n//> another\ny := 2\n", 0, ">", "[1 2] [3]"},
+		// nor is the blank line after the headers
+		{"// Commit: 1234\n\n//> a note\nx := 1\ny := 2\n", 1, ">", "[0] [1 2]"},
+	}
+	for _, tt := range tests {
+		got := showLineNumbers(sectionSlice(parse(lang, []byte(tt.code), tt.first, tt.marker)))
+		if got != tt.want {
+			t.Errorf("parse(%q, %q): got line numbers %v, want %v", tt.code, tt.marker, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,454 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/alecthomas/chroma/lexers"
+)
+
+// ## Language registry
+// Every artifact is highlighted by a chroma lexer and split into sections on
+// its line comments, so all lazylit needs to know about a language is which
+// lexer to use and what a comment looks like. The built-in table below covers
+// the common languages chroma supports; `lazylit.yaml` can add to it or
+// override entries (see `LanguageConfig`).
+
+// a `Language` describes a programming language
+type Language struct {
+	// the chroma lexer name of the language
+	name string
+	// The comment delimiter; empty if the language only has block comments
+	symbol string
+	// Block comment delimiters, if the language has any
+	blocks []blockComment
+	// The regular expression to match the comment delimiter
+	commentMatcher *regexp.Regexp
+	// The regular expression to match note comments in "marked" mode,
+	// i.e. the comment delimiter followed by the note marker
+	noteMatcher *regexp.Regexp
+	// Extracts header values from comment lines
+	headerParser *regexp.Regexp
+	// How header lines start and end, i.e. the comment delimiters
+	headerStart, headerEnd string
+}
+
+// the chroma lexer name of the language
+func (lang *Language) Name() string {
+	return lang.name
+}
+
+// a `Languages` is the registry of every language a build knows
+type Languages struct {
+	// keyed by file extension
+	byExtension map[string]*Language
+	// keyed by chroma's canonical lexer name
+	byLexer map[string]*Language
+}
+
+// the block comment styles shared by several languages
+var (
+	cBlocks       = []blockComment{{"/*", "*/", "*"}}
+	pythonBlocks  = []blockComment{{`"""`, `"""`, ""}, {"'''", "'''", ""}}
+	haskellBlocks = []blockComment{{"{-", "-}", ""}}
+	htmlBlocks    = []blockComment{{"<!--", "-->", ""}}
+	luaBlocks     = []blockComment{{"--[[", "]]", ""}}
+)
+
+// extensions, chroma lexer name, line comment symbol (if any) and block
+// comment delimiters (if any)
+var builtinLanguages = []struct {
+	extensions []string
+	name       string
+	symbol     string
+	blocks     []blockComment
+}{
+	{[]string{".go"}, "go", "//", cBlocks},
+	{[]string{".py", ".pyw"}, "python", "#", pythonBlocks},
+	{[]string{".js", ".mjs", ".cjs"}, "javascript", "//", cBlocks},
+	{[]string{".jsx"}, "react", "//", cBlocks},
+	{[]string{".ts", ".tsx"}, "typescript", "//", cBlocks},
+	{[]string{".java"}, "java", "//", cBlocks},
+	{[]string{".kt", ".kts"}, "kotlin", "//", cBlocks},
+	{[]string{".scala"}, "scala", "//", cBlocks},
+	{[]string{".groovy", ".gradle"}, "groovy", "//", cBlocks},
+	{[]string{".c", ".h"}, "c", "//", cBlocks},
+	{[]string{".cc", ".cpp", ".cxx", ".hh", ".hpp", ".hxx"}, "cpp", "//", cBlocks},
+	{[]string{".cs"}, "csharp", "//", cBlocks},
+	{[]string{".m"}, "objective-c", "//", cBlocks},
+	{[]string{".swift"}, "swift", "//", cBlocks},
+	{[]string{".rs"}, "rust", "//", cBlocks},
+	{[]string{".dart"}, "dart", "//", cBlocks},
+	{[]string{".php"}, "php", "//", cBlocks},
+	{[]string{".zig"}, "zig", "//", cBlocks},
+	{[]string{".d"}, "d", "//", cBlocks},
+	{[]string{".sol"}, "solidity", "//", cBlocks},
+	{[]string{".proto"}, "protobuf", "//", cBlocks},
+	{[]string{".rb", ".rake"}, "ruby", "#", nil},
+	{[]string{".pl", ".pm"}, "perl", "#", nil},
+	{[]string{".sh", ".bash", ".zsh", ".ksh"}, "bash", "#", nil},
+	{[]string{".fish"}, "fish", "#", nil},
+	{[]string{".ps1", ".psm1"}, "powershell", "#", nil},
+	{[]string{".r", ".R"}, "r", "#", nil},
+	{[]string{".jl"}, "julia", "#", nil},
+	{[]string{".ex", ".exs"}, "elixir", "#", nil},
+	{[]string{".cr"}, "crystal", "#", nil},
+	{[]string{".nim"}, "nim", "#", nil},
+	{[]string{".yaml", ".yml"}, "yaml", "#", nil},
+	{[]string{".toml"}, "toml", "#", nil},
+	{[]string{".tf", ".tfvars"}, "terraform", "#", nil},
+	{[]string{".hcl"}, "hcl", "#", nil},
+	{[]string{".nix"}, "nix", "#", nil},
+	{[]string{".cmake"}, "cmake", "#", nil},
+	{[]string{".mk", ".mak"}, "make", "#", nil},
+	{[]string{".dockerfile"}, "docker", "#", nil},
+	{[]string{".graphql", ".gql"}, "graphql", "#", nil},
+	{[]string{".hs"}, "haskell", "--", haskellBlocks},
+	{[]string{".elm"}, "elm", "--", haskellBlocks},
+	{[]string{".lua"}, "lua", "--", luaBlocks},
+	{[]string{".sql"}, "sql", "--", cBlocks},
+	{[]string{".erl", ".hrl"}, "erlang", "%", nil},
+	{[]string{".tex"}, "tex", "%", nil},
+	{[]string{".clj", ".cljs"}, "clojure", ";", nil},
+	{[]string{".lisp", ".cl"}, "common-lisp", ";", nil},
+	{[]string{".el"}, "emacslisp", ";", nil},
+	{[]string{".scm", ".ss"}, "scheme", ";", nil},
+	{[]string{".rkt"}, "racket", ";", nil},
+	{[]string{".css"}, "css", "", cBlocks},
+	{[]string{".scss"}, "scss", "//", cBlocks},
+	{[]string{".html", ".htm"}, "html", "", htmlBlocks},
+	{[]string{".xml", ".xsd", ".svg"}, "xml", "", htmlBlocks},
+	{[]string{".ml", ".mli"}, "ocaml", "", []blockComment{{"(*", "*)", ""}}},
+}
+
+// build the registry from the built-in table and `config`
+func NewLanguages(config *Config) (*Languages, error) {
+	languages := make(map[string]*Language)
+	for _, l := range builtinLanguages {
+		for _, ext := range l.extensions {
+			languages[ext] = &Language{name: l.name, symbol: l.symbol, blocks: l.blocks}
+		}
+	}
+
+	for _, lc := range config.Languages {
+		if !strings.HasPrefix(lc.Extension, ".") {
+			return nil, fmt.Errorf("%v: language extension %q must start with a dot", ConfigFileName, lc.Extension)
+		}
+		lang, ok := languages[lc.Extension]
+		if !ok {
+			lang = new(Language)
+			languages[lc.Extension] = lang
+		}
+		if lc.Lexer != "" {
+			lang.name = lc.Lexer
+		}
+		if lc.Symbol != "" {
+			lang.symbol = lc.Symbol
+		}
+		if lc.BlockStart != "" || lc.BlockEnd != "" {
+			if lc.BlockStart == "" || lc.BlockEnd == "" {
+				return nil, fmt.Errorf("%v: language %v needs both block_start and block_end", ConfigFileName, lc.Extension)
+			}
+			lang.blocks = []blockComment{{lc.BlockStart, lc.BlockEnd, ""}}
+		}
+		if lang.name == "" || (lang.symbol == "" && len(lang.blocks) == 0) {
+			return nil, fmt.Errorf("%v: language %v needs a lexer and a comment symbol or block delimiters", ConfigFileName, lc.Extension)
+		}
+	}
+
+	for ext, lang := range languages {
+		if lexers.Get(lang.name) == nil {
+			return nil, fmt.Errorf("No chroma lexer named %q (for %v)", lang.name, ext)
+		}
+		compileLanguage(lang, config.NoteMarker)
+	}
+
+	// walk the tables in order so the same lexer always maps to the same
+	// `Language`, with configured languages taking precedence
+	ls := &Languages{byExtension: languages, byLexer: make(map[string]*Language)}
+	for _, l := range builtinLanguages {
+		ls.registerLexer(languages[l.extensions[0]], false)
+	}
+	for _, lc := range config.Languages {
+		ls.registerLexer(languages[lc.Extension], true)
+	}
+	return ls, nil
+}
+
+// the `Language` for files ending in `ext`, e.g. `.go`
+func (ls *Languages) ForExtension(ext string) (*Language, bool) {
+	lang, ok := ls.byExtension[ext]
+	return lang, ok
+}
+
+// create the regular expressions based on the language comment symbol.
+// Languages without line comments write their headers as one-line block
+// comments, e.g. `<!-- Commit: ... -->`.
+func compileLanguage(lang *Language, noteMarker string) {
+	lang.headerStart, lang.headerEnd = lang.symbol, ""
+	if lang.symbol == "" {
+		lang.headerStart, lang.headerEnd = lang.blocks[0].start, lang.blocks[0].end
+	} else {
+		symbol := regexp.QuoteMeta(lang.symbol)
+		lang.commentMatcher = regexp.MustCompile(`^\s*` + symbol + `\s?`)
+		lang.noteMatcher = regexp.MustCompile(`^\s*` + symbol + regexp.QuoteMeta(noteMarker) + `\s?`)
+	}
+	start, end := regexp.QuoteMeta(lang.headerStart), regexp.QuoteMeta(lang.headerEnd)
+	lang.headerParser = regexp.MustCompile(`^\s*` + start + `\s*(\w+):\s*(.*?)\s*` + end + `\s*$`)
+}
+
+// a header line for `lang`, as written by `lazylit new`
+func (lang *Language) Header(name, value string) string {
+	line := lang.headerStart + " " + name + ": " + value
+	if lang.headerEnd != "" {
+		line += " " + lang.headerEnd
+	}
+	return line + "\n"
+}
+
+// ### Detecting the language of an artifact
+// Most artifacts are recognised by their extension, but that doesn't work for
+// files like `Makefile.jul_18_2020`. `Detect` tries, in order:
+//
+// 1. an explicit `Language:` header naming a chroma lexer,
+// 2. the file extension, once the date suffix has been stripped,
+// 3. well-known file names such as `Makefile` or `Dockerfile`,
+// 4. a `#!` line naming the interpreter,
+// 5. chroma's content analysis (`lexers.Analyse`).
+
+// well-known file names without a useful extension, and their lexer
+var wellKnownFiles = map[string]string{
+	"makefile":       "make",
+	"gnumakefile":    "make",
+	"dockerfile":     "docker",
+	"containerfile":  "docker",
+	"jenkinsfile":    "groovy",
+	"build":          "python",
+	"build.bazel":    "python",
+	"workspace":      "python",
+	"cmakelists.txt": "cmake",
+	"rakefile":       "ruby",
+	"gemfile":        "ruby",
+	"vagrantfile":    "ruby",
+	"pkgbuild":       "bash",
+	".bashrc":        "bash",
+	".zshrc":         "bash",
+}
+
+// interpreters whose name isn't a chroma lexer name
+var shebangInterpreters = map[string]string{
+	"sh":      "bash",
+	"dash":    "bash",
+	"ash":     "bash",
+	"zsh":     "bash",
+	"ksh":     "bash",
+	"node":    "javascript",
+	"nodejs":  "javascript",
+	"deno":    "typescript",
+	"pwsh":    "powershell",
+	"Rscript": "r",
+	"runghc":  "haskell",
+}
+
+// matches the date suffix of an artifact file name, e.g. `.jul_18_2020` in
+// `Makefile.jul_18_2020` or `_apr_6_2012` in `gocco_apr_6_2012.go`
+var dateSuffixMatcher = regexp.MustCompile(`(?i)[._][a-z]{3}_\d{1,2}_\d{2,4}`)
+
+// matches a header line without knowing the comment symbol yet
+var anyHeaderMatcher = regexp.MustCompile(`^\s*[^\w\s]+\s*(\w+):\s*(.*?)\s*(?:-->|\*/|\*\)|-\})?\s*$`)
+
+// register `lang` under its lexer's canonical name, unless `override` is
+// false and another language already claimed it
+func (ls *Languages) registerLexer(lang *Language, override bool) {
+	key := lexers.Get(lang.name).Config().Name
+	if _, ok := ls.byLexer[key]; !ok || override {
+		ls.byLexer[key] = lang
+	}
+}
+
+// look up a `Language` by any chroma lexer name or alias
+func (ls *Languages) ForLexer(name string) (*Language, error) {
+	lexer := lexers.Get(name)
+	if lexer == nil {
+		return nil, fmt.Errorf("No chroma lexer named %q", name)
+	}
+	if lang, ok := ls.byLexer[lexer.Config().Name]; ok {
+		return lang, nil
+	}
+	return nil, fmt.Errorf("No comment symbol known for %v; add it to %v", lexer.Config().Name, ConfigFileName)
+}
+
+// strip the date suffix from an artifact file name
+func stripDateSuffix(name string) string {
+	return dateSuffixMatcher.ReplaceAllString(name, "")
+}
+
+// work out the `Language` of the artifact `file` with contents `data`
+func (ls *Languages) Detect(file string, data []byte) (*Language, error) {
+	lines := strings.Split(string(data), "\n")
+	body := lines
+	for i, line := range lines {
+		matches := anyHeaderMatcher.FindStringSubmatch(line)
+		if matches == nil {
+			body = lines[i:]
+			break
+		}
+		if matches[1] == "Language" {
+			return ls.ForLexer(matches[2])
+		}
+	}
+
+	name := stripDateSuffix(path.Base(filepath.ToSlash(file)))
+	if lang, ok := ls.byExtension[path.Ext(name)]; ok {
+		return lang, nil
+	}
+
+	lower := strings.ToLower(name)
+	for known, lexer := range wellKnownFiles {
+		// also accept names like `crazy_makefile`
+		if lower == known || strings.HasSuffix(lower, "_"+known) || strings.HasSuffix(lower, "-"+known) {
+			return ls.ForLexer(lexer)
+		}
+	}
+
+	for _, line := range body {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#!") {
+			return ls.ForLexer(shebangLexer(line))
+		}
+		break
+	}
+
+	if lexer := lexers.Analyse(strings.Join(body, "\n")); lexer != nil {
+		return ls.ForLexer(lexer.Config().Name)
+	}
+	return nil, fmt.Errorf("Unable to detect the language; add a Language header or add its extension to %v", ConfigFileName)
+}
+
+// the lexer name for a `#!` line such as `#!/usr/bin/env python3`
+func shebangLexer(line string) string {
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	if len(fields) == 0 {
+		return ""
+	}
+	interp := filepath.Base(fields[0])
+	if interp == "env" {
+		interp = ""
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") && !strings.Contains(f, "=") {
+				interp = filepath.Base(f)
+				break
+			}
+		}
+	}
+	interp = strings.TrimRight(interp, "0123456789.")
+	if lexer, ok := shebangInterpreters[interp]; ok {
+		return lexer
+	}
+	return interp
+}
+
+// ### Block comments
+// A `blockComment` describes delimiters such as `/*` and `*/`, or Python's
+// triple quotes. Only blocks that stand on their own lines become notes;
+// trailing and inline block comments stay part of the code.
+type blockComment struct {
+	start, end string
+	// stripped from the start of each line inside the block, like the
+	// leading `*` of Javadoc-style comments
+	linePrefix string
+}
+
+// if a block comment starts on line `i` and ends at the end of a line, return
+// the index of that last line and the text of the comment. With a `marker`,
+// only blocks opened by the delimiter and the marker (e.g. `/*>`) count.
+func (lang *Language) blockAt(lines [][]byte, i int, marker string) (int, []byte, bool) {
+	first := bytes.TrimSpace(lines[i])
+	for _, b := range lang.blocks {
+		start, end := []byte(b.start+marker), []byte(b.end)
+		if !bytes.HasPrefix(first, start) {
+			continue
+		}
+		rest := first[len(start):]
+		for j := i; j < len(lines); j++ {
+			line := rest
+			if j > i {
+				line = bytes.TrimSpace(lines[j])
+			}
+			k := bytes.Index(line, end)
+			if k < 0 {
+				continue
+			}
+			if k+len(end) != len(line) {
+				// code follows the comment on the same line
+				return 0, nil, false
+			}
+			return j, b.text(lines[i:j+1], len(start)), true
+		}
+		return 0, nil, false
+	}
+	return 0, nil, false
+}
+
+// the text inside a block comment spanning `lines`, with the delimiters (the
+// opening one being `open` bytes long), any `linePrefix` and the common
+// indentation removed
+func (b blockComment) text(lines [][]byte, open int) []byte {
+	out := make([][]byte, len(lines))
+	for i, line := range lines {
+		if i == 0 {
+			line = bytes.TrimSpace(line)[open:]
+		}
+		if i == len(lines)-1 {
+			line = bytes.TrimRight(line, " \t")
+			line = line[:len(line)-len(b.end)]
+		}
+		if b.linePrefix != "" {
+			trimmed := bytes.TrimLeft(line, " \t")
+			if i == 0 {
+				// `/**` opens a Javadoc comment
+				trimmed = bytes.TrimLeft(trimmed, b.linePrefix)
+				line = trimmed
+			} else if bytes.HasPrefix(trimmed, []byte(b.linePrefix)) {
+				line = bytes.TrimPrefix(trimmed[len(b.linePrefix):], []byte(" "))
+			}
+		}
+		out[i] = line
+	}
+	out[0] = bytes.TrimLeft(out[0], " \t")
+
+	// strip the indentation shared by the remaining lines, like Python's
+	// `inspect.cleandoc`
+	common := -1
+	for _, line := range out[1:] {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		n := len(line) - len(bytes.TrimLeft(line, " \t"))
+		if common < 0 || n < common {
+			common = n
+		}
+	}
+	for i := 1; i < len(out) && common > 0; i++ {
+		if len(out[i]) >= common {
+			out[i] = out[i][common:]
+		} else {
+			out[i] = bytes.TrimLeft(out[i], " \t")
+		}
+	}
+
+	// drop blank first and last lines left over from the delimiters
+	if len(out) > 1 && len(bytes.TrimSpace(out[0])) == 0 {
+		out = out[1:]
+	}
+	if len(out) > 1 && len(bytes.TrimSpace(out[len(out)-1])) == 0 {
+		out = out[:len(out)-1]
+	}
+	return append(bytes.Join(out, []byte("\n")), '\n')
+}
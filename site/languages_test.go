@@ -0,0 +1,112 @@
+package site
+
+import (
+	"testing"
+)
+
+func TestNewLanguages(t *testing.T) {
+	config := DefaultConfig()
+	config.Languages = []LanguageConfig{
+		{Extension: ".star", Lexer: "python", Symbol: "#"},
+		// overrides only the comment symbol of a built-in
+		{Extension: ".sql", Symbol: "#"},
+	}
+	ls, err := NewLanguages(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ext, name, header string
+	}{
+		{".go", "go", "// Commit: 1234\n"},
+		{".star", "python", "# Commit: 1234\n"},
+		{".sql", "sql", "# Commit: 1234\n"},
+		{".css", "css", "/* Commit: 1234 */\n"},
+		{".html", "html", "<!-- Commit: 1234 -->\n"},
+	}
+	for _, tt := range tests {
+		lang, ok := ls.ForExtension(tt.ext)
+		if !ok {
+			t.Errorf("no language for %v", tt.ext)
+			continue
+		}
+		if lang.Name() != tt.name {
+			t.Errorf("%v: got lexer %v, want %v", tt.ext, lang.Name(), tt.name)
+		}
+		if got := lang.Header("Commit", "1234"); got != tt.header {
+			t.Errorf("%v: got header %q, want %q", tt.ext, got, tt.header)
+		}
+	}
+	if _, ok := ls.ForExtension(".nosuch"); ok {
+		t.Errorf("got a language for an unknown extension")
+	}
+}
+
+func TestNewLanguagesErrors(t *testing.T) {
+	tests := []LanguageConfig{
+		{Extension: "star", Lexer: "python", Symbol: "#"},
+		{Extension: ".star", Symbol: "#"},
+		{Extension: ".star", Lexer: "python"},
+		{Extension: ".star", Lexer: "python", BlockStart: "/*"},
+		{Extension: ".star", Lexer: "nosuchlexer", Symbol: "#"},
+	}
+	for _, lc := range tests {
+		config := DefaultConfig()
+		config.Languages = []LanguageConfig{lc}
+		if _, err := NewLanguages(config); err == nil {
+			t.Errorf("NewLanguages(%+v) succeeded, want an error", lc)
+		}
+	}
+}
+
+func TestStripDateSuffix(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"gocco_apr_6_2012.go", "gocco.go"},
+		{"tiddlylisp.may_16_2020.py", "tiddlylisp.py"},
+		{"Makefile.jul_18_2020", "Makefile"},
+		{"Makefile.JUL_18_20", "Makefile"},
+		{"main.go", "main.go"},
+		{"notes_2020.md", "notes_2020.md"},
+	}
+	for _, tt := range tests {
+		if got := stripDateSuffix(tt.name); got != tt.want {
+			t.Errorf("stripDateSuffix(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	ls, err := NewLanguages(DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		file, data string
+		// the lexer name, or "" for an error
+		want string
+	}{
+		{"gocco/gocco_apr_6_2012.go", "package main\n", "go"},
+		{"lisp/tiddlylisp.may_16_2020.py", "", "python"},
+		{"build/Makefile.jul_18_2020", "all:\n", "make"},
+		{"build/crazy_makefile", "all:\n", "make"},
+		{"build/Dockerfile", "FROM scratch\n", "docker"},
+		{"bin/deploy.jul_18_2020", "#!/bin/sh\necho hi\n", "bash"},
+		{"bin/tool", "\n#!/usr/bin/env -S python3 -u\n", "python"},
+		// a `Language` header beats the extension
+		{"a/b.txt", "# Commit: 1234\n# Language: ruby\n\nputs 1\n", "ruby"},
+		{"a/b.go", "// Language: nosuchlexer\n", ""},
+	}
+	for _, tt := range tests {
+		lang, err := ls.Detect(tt.file, []byte(tt.data))
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("Detect(%q) = %v, want an error", tt.file, lang.Name())
+		case tt.want != "" && err != nil:
+			t.Errorf("Detect(%q): %v", tt.file, err)
+		case tt.want != "" && lang.Name() != tt.want:
+			t.Errorf("Detect(%q) = %v, want %v", tt.file, lang.Name(), tt.want)
+		}
+	}
+}
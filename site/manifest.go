@@ -0,0 +1,191 @@
+package site
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ## Incremental builds
+// Every build leaves a manifest in the output directory recording each file it wrote and a
+// hash of everything that went into it. The next build skips pages whose
+// inputs haven't changed, and deletes files that are no longer produced, e.g.
+// because their artifact was deleted. A different lazylit version, template
+// set, configuration or set of installed diagram tools invalidates the whole
+// manifest, as does `-force`.
+
+const manifestFileName = ".lazylit-manifest.json"
+
+// a `Manifest` records what a build wrote to the output directory, and from
+// what. Paths are slash-separated and relative to that directory.
+type Manifest struct {
+	Version   string `json:"version"`
+	Templates string `json:"templates"`
+	Config    string `json:"config"`
+	// output file -> hash of its inputs; empty for files that are cheap to
+	// regenerate and only tracked so they can be pruned
+	Outputs map[string]string `json:"outputs"`
+	// the output directory
+	dir string
+	mu  sync.Mutex
+}
+
+// a manifest for the build about to happen, with the page templates hashing
+// to `templates`
+func newManifest(dir string, config *Config, templates string) *Manifest {
+	m := emptyManifest(dir)
+	m.Version = Version
+	m.Templates = hashOf([]byte(templates), []byte(SEARCH_JS), []byte(THEME_JS), []byte(ARTIFACTS_JS), []byte(Css))
+	m.Config = hashOf([]byte(configHash(config)), diagramToolPaths())
+	return m
+}
+
+// a manifest that matches nothing
+func emptyManifest(dir string) *Manifest {
+	return &Manifest{Outputs: make(map[string]string), dir: dir}
+}
+
+// the manifest left by the previous build in `dir`. A missing or unreadable
+// manifest is an empty one, which simply means everything gets rebuilt.
+func loadManifest(dir string, logger *log.Logger) *Manifest {
+	m := emptyManifest(dir)
+	data, err := ioutil.ReadFile(filepath.Join(dir, manifestFileName))
+	if err != nil {
+		return m
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		logger.Printf("Ignoring unreadable %v: %v", manifestFileName, err)
+		return emptyManifest(dir)
+	}
+	return m
+}
+
+// whether pages recorded in `prev` can be reused by the build of `m`
+func (m *Manifest) compatible(prev *Manifest) bool {
+	return prev.Version == m.Version && prev.Templates == m.Templates && prev.Config == m.Config
+}
+
+// whether `name` was built from inputs hashing to `hash` and is still there
+func (m *Manifest) upToDate(name, hash string) bool {
+	m.mu.Lock()
+	recorded, ok := m.Outputs[name]
+	m.mu.Unlock()
+	if !ok || recorded != hash {
+		return false
+	}
+	_, err := os.Stat(m.path(name))
+	return err == nil
+}
+
+// the hash `name` was recorded with, or "" if it wasn't
+func (m *Manifest) hash(name string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.Outputs[name]
+}
+
+// note that the build produced `name` from inputs hashing to `hash`
+func (m *Manifest) record(name, hash string) {
+	m.mu.Lock()
+	m.Outputs[name] = hash
+	m.mu.Unlock()
+}
+
+// carry over the files `prev` recorded in the directories `dirs` that this
+// build didn't produce, so they aren't pruned. They are recorded without a
+// hash, so they are rebuilt once they can be.
+func (m *Manifest) keep(prev *Manifest, dirs map[string]bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for name := range prev.Outputs {
+		if _, ok := m.Outputs[name]; !ok && dirs[strings.SplitN(name, "/", 2)[0]] {
+			m.Outputs[name] = ""
+		}
+	}
+}
+
+// delete the files `prev` recorded that this build didn't produce, along
+// with any directories left empty
+func (m *Manifest) prune(prev *Manifest, logger *log.Logger) {
+	var stale []string
+	for name := range prev.Outputs {
+		if _, ok := m.Outputs[name]; !ok {
+			stale = append(stale, name)
+		}
+	}
+	sort.Strings(stale)
+	for _, name := range stale {
+		if err := os.Remove(m.path(name)); err != nil && !os.IsNotExist(err) {
+			logger.Printf("Unable to remove stale %v: %v", m.path(name), err)
+			continue
+		}
+		logger.Printf("removed stale %v", m.path(name))
+		// fails harmlessly unless the directory is now empty
+		if dir := path.Dir(name); dir != "." {
+			os.Remove(m.path(dir))
+		}
+	}
+}
+
+func (m *Manifest) save() error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(m.dir, manifestFileName), append(data, '\n'), 0644)
+}
+
+// where the output `name` is on disk
+func (m *Manifest) path(name string) string {
+	return filepath.Join(m.dir, filepath.FromSlash(name))
+}
+
+// the hash of a snapshot page's inputs: its file and the revisions it links to
+func snapshotHash(code []byte, otherRevs []Snapshot) string {
+	parts := [][]byte{code}
+	for _, r := range otherRevs {
+		parts = append(parts, []byte(r.Destination()), []byte(r.CommitDateString))
+	}
+	return hashOf(parts...)
+}
+
+// the hash of the configuration, which affects how every page is rendered
+func configHash(config *Config) string {
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		// never happens for a `Config`; rebuilding everything is safe anyway
+		return ""
+	}
+	return hashOf(data)
+}
+
+func hashOf(parts ...[]byte) string {
+	h := sha256.New()
+	for _, p := range parts {
+		// length-prefix each part so that different splits can't collide
+		fmt.Fprintf(h, "%d:", len(p))
+		h.Write(p)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// write `data` to `name` unless it already has exactly that content, so
+// unchanged files keep their timestamps
+func writeIfChanged(name string, data []byte) error {
+	if old, err := ioutil.ReadFile(name); err == nil && bytes.Equal(old, data) {
+		return nil
+	}
+	return ioutil.WriteFile(name, data, 0644)
+}
@@ -0,0 +1,169 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	gtext "github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// ## Markdown
+// Notes are CommonMark, rendered by goldmark, plus the extensions named by
+// `markdown_extensions` in `lazylit.yaml`. By default those are GitHub's:
+// tables, strikethrough, autolinks, task lists and footnotes, along with ids
+// on headings so they can be linked to and curly quotes. Fenced
+// code blocks in notes are highlighted like the code column, or drawn if they
+// are diagrams. Raw HTML in notes is passed through, as the notes are written
+// by the site's own authors.
+
+// the optional Markdown extensions, by their name in `lazylit.yaml`
+var markdownExtensions = map[string]goldmark.Option{
+	"table":           goldmark.WithExtensions(extension.Table),
+	"strikethrough":   goldmark.WithExtensions(extension.Strikethrough),
+	"autolink":        goldmark.WithExtensions(extension.Linkify),
+	"tasklist":        goldmark.WithExtensions(extension.TaskList),
+	"footnote":        goldmark.WithExtensions(extension.NewFootnote(extension.WithFootnoteIDPrefixFunction(footnotePrefix))),
+	"heading_ids":     goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+	"definition_list": goldmark.WithExtensions(extension.DefinitionList),
+	"typographer":     goldmark.WithExtensions(extension.Typographer),
+	"math":            goldmark.WithExtensions(mathExtension),
+}
+
+// the extensions used unless `lazylit.yaml` says otherwise
+var defaultMarkdownExtensions = []string{"table", "strikethrough", "autolink", "tasklist", "footnote", "heading_ids", "typographer"}
+
+// an error unless every name in `names` is a known extension
+func validMarkdownExtensions(names []string) error {
+	for _, name := range names {
+		if _, ok := markdownExtensions[name]; !ok {
+			known := make([]string, 0, len(markdownExtensions))
+			for k := range markdownExtensions {
+				known = append(known, k)
+			}
+			sort.Strings(known)
+			return fmt.Errorf("unknown Markdown extension %q; try %v", name, strings.Join(known, ", "))
+		}
+	}
+	return nil
+}
+
+// a Markdown renderer with the extensions in `names`. Diagrams are drawn by
+// `d`, or shown as code if it is nil.
+func newMarkdown(names []string, d *diagrams) goldmark.Markdown {
+	opts := []goldmark.Option{
+		goldmark.WithRendererOptions(
+			html.WithUnsafe(),
+			html.WithXHTML(),
+			renderer.WithNodeRenderers(util.Prioritized(fencedCodeRenderer{d}, 100)),
+		),
+	}
+	for _, name := range names {
+		opts = append(opts, markdownExtensions[name])
+	}
+	return goldmark.New(opts...)
+}
+
+// render the Markdown `text`, from `file`, as HTML, as part of the page
+// `ids`
+func (b *builder) markdown(file string, text []byte, ids *pageIDs) []byte {
+	return renderMarkdown(b.md, file, text, ids)
+}
+
+// render `text` as HTML. A page's notes are rendered one section at a time,
+// so `ids` keeps the ids of their headings and footnotes unique across the
+// page; nil is a page of its own.
+func renderMarkdown(md goldmark.Markdown, file string, text []byte, ids *pageIDs) []byte {
+	if ids == nil {
+		ids = newPageIDs()
+	}
+	ids.sections++
+	doc := md.Parser().Parse(gtext.NewReader(text), parser.WithContext(parser.NewContext(parser.WithIDs(ids.headings))))
+	// so problems with diagrams can say where they are
+	doc.OwnerDocument().AddMeta(fileMeta, file)
+	doc.OwnerDocument().AddMeta(footnoteMeta, fmt.Sprintf("s%d-", ids.sections))
+	buf := new(bytes.Buffer)
+	if err := md.Renderer().Render(buf, text, doc); err != nil {
+		// goldmark only fails when writing, which a buffer doesn't
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// the document metadata naming the file being rendered
+const fileMeta = "lazylit-file"
+
+// the document metadata holding the prefix of its footnotes' ids
+const footnoteMeta = "lazylit-footnote-prefix"
+
+// a `pageIDs` is what the notes of a page share so their ids don't clash:
+// the heading ids given out so far, e.g. a second `## Usage` gets `usage-1`,
+// and the number of sections, each of whose footnotes are prefixed by it,
+// e.g. `s2-fn:1`
+type pageIDs struct {
+	headings parser.IDs
+	sections int
+}
+
+func newPageIDs() *pageIDs {
+	return &pageIDs{headings: parser.NewContext().IDs()}
+}
+
+func footnotePrefix(node ast.Node) []byte {
+	prefix, _ := node.OwnerDocument().Meta()[footnoteMeta].(string)
+	return []byte(prefix)
+}
+
+// a `fencedCodeRenderer` highlights fenced code blocks with chroma, using
+// the language named after the opening fence, and draws diagrams
+type fencedCodeRenderer struct {
+	diagrams *diagrams
+}
+
+func (r fencedCodeRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, r.render)
+}
+
+func (r fencedCodeRenderer) render(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.FencedCodeBlock)
+	code := new(bytes.Buffer)
+	for i := 0; i < n.Lines().Len(); i++ {
+		segment := n.Lines().At(i)
+		code.Write(segment.Value(source))
+	}
+	lines := strings.Split(strings.TrimSuffix(code.String(), "\n"), "\n")
+
+	lang := ""
+	if n.Info != nil {
+		lang = string(n.Language(source))
+	}
+	if r.diagrams != nil && isDiagram(lang) {
+		file, _ := n.OwnerDocument().Meta()[fileMeta].(string)
+		if svg := r.diagrams.draw(file, lang, code.Bytes()); svg != nil {
+			w.WriteString(`<div class="diagram">` + string(svg) + "</div>\n")
+			return ast.WalkSkipChildren, nil
+		}
+	}
+	lexer := lexers.Get("plaintext")
+	if l := lexers.Get(lang); lang != "" && l != nil {
+		lexer = l
+	}
+	highlighted, err := highlightLines(&Language{name: lexer.Config().Name}, lines)
+	if err != nil {
+		return ast.WalkStop, err
+	}
+	w.WriteString(highlightStart + strings.Join(highlighted, "\n") + highlightEnd + "\n")
+	return ast.WalkSkipChildren, nil
+}
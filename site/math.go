@@ -0,0 +1,559 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	gtext "github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// ## Math
+// Notes on numerical code need formulas. With the `math` extension, which is
+// off by default as `$` also starts shell and make variables, `$...$`
+// in a note is inline math and `$$...$$` is display math, both in LaTeX. They
+// are turned into MathML when the site is built, which browsers render
+// natively, so pages need no script and work offline. The TeX is kept in an
+// annotation, for copying and for the search index.
+//
+// Only the LaTeX commonly used in formulas is understood: scripts,
+// fractions, roots, Greek letters, operators and relations, accents, fonts,
+// `\left`/`\right`, `\text` and the `matrix`, `cases` and `aligned`
+// environments. Anything else is shown as an error in the formula.
+//
+// Math is found in the Markdown, after comment delimiters were stripped, so
+// `_`, `*` and `\` inside it are not taken for emphasis or escapes. Inline
+// math ends at the next `$` on the line. Like in Pandoc, the opening `$` can't
+// be followed by a space and the closing one can't follow a space; it must
+// also end the word, followed by a space, punctuation or the end of the line.
+// Otherwise it's all text, so prices like $5 and $10 and variables like
+// `$HOME/$USER` or `$(OBJS):$(SRCS)` stay text.
+// Display math can span lines, but not blank ones.
+
+// the `math` extension
+var mathExtension = &mathExtender{}
+
+type mathExtender struct{}
+
+func (e *mathExtender) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithInlineParsers(util.Prioritized(mathParser{}, 150)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(mathRenderer{}, 150)))
+}
+
+// a `mathNode` is a formula in a note
+type mathNode struct {
+	ast.BaseInline
+	tex     string
+	display bool
+}
+
+var kindMath = ast.NewNodeKind("Math")
+
+func (n *mathNode) Kind() ast.NodeKind {
+	return kindMath
+}
+
+func (n *mathNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"TeX": n.tex}, nil)
+}
+
+// a `mathParser` finds formulas between dollar signs
+type mathParser struct{}
+
+func (p mathParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (p mathParser) Parse(parent ast.Node, block gtext.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	if bytes.HasPrefix(line, []byte("$$")) {
+		return p.parseDisplay(block)
+	}
+	// inline math ends on the same line
+	if len(line) < 3 || isSpace(line[1]) {
+		return nil
+	}
+	for i := 2; i < len(line); i++ {
+		switch {
+		case line[i] == '\\':
+			i++
+		case line[i] == '$':
+			if isSpace(line[i-1]) || i+1 < len(line) && !endsMath(line[i+1]) {
+				// like in "from $5 to $10" or "$HOME/$USER"
+				return nil
+			}
+			block.Advance(i + 1)
+			return &mathNode{tex: string(line[1:i])}
+		}
+	}
+	return nil
+}
+
+// display math, from `$$` to the next `$$`, possibly on another line
+func (p mathParser) parseDisplay(block gtext.Reader) ast.Node {
+	l, pos := block.Position()
+	block.Advance(2)
+	tex := new(bytes.Buffer)
+	for {
+		line, _ := block.PeekLine()
+		if line == nil {
+			block.SetPosition(l, pos)
+			return nil
+		}
+		if i := bytes.Index(line, []byte("$$")); i >= 0 {
+			tex.Write(line[:i])
+			block.Advance(i + 2)
+			return &mathNode{tex: strings.TrimSpace(tex.String()), display: true}
+		}
+		tex.Write(line)
+		block.AdvanceLine()
+	}
+}
+
+// whether `c` may follow the `$` closing inline math
+func endsMath(c byte) bool {
+	return isSpace(c) || strings.IndexByte(".,;:!?'\")]}-", c) >= 0
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// a `mathRenderer` writes formulas as MathML
+type mathRenderer struct{}
+
+func (r mathRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindMath, func(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering {
+			n := node.(*mathNode)
+			w.WriteString(mathML(n.tex, n.display))
+		}
+		return ast.WalkSkipChildren, nil
+	})
+}
+
+// ### LaTeX to MathML
+
+// the `tex` formula as a MathML element
+func mathML(tex string, display bool) string {
+	t := &texParser{src: tex, display: display}
+	body := t.row("")
+	for t.pos < len(t.src) {
+		// a `}`, `&`, `\\`, `\right` or `\end` out of place; carry on after it
+		switch {
+		case t.at(`\right`):
+			t.pos += len(`\right`)
+			t.delimiter()
+		case t.at(`\end`):
+			t.pos += len(`\end`)
+			t.rawArgument()
+		case t.at(`\\`):
+			t.pos += 2
+		default:
+			t.pos++
+		}
+		body += t.row("")
+	}
+	attrs := ""
+	if display {
+		attrs = ` display="block"`
+	}
+	return fmt.Sprintf(`<math xmlns="http://www.w3.org/1998/Math/MathML"%v><semantics><mrow>%v</mrow><annotation encoding="application/x-tex">%v</annotation></semantics></math>`,
+		attrs, body, html.EscapeString(tex))
+}
+
+// a `texParser` turns LaTeX into MathML, one element at a time
+type texParser struct {
+	src     string
+	pos     int
+	display bool
+}
+
+// the elements up to the end, or up to the command or character `end`, which
+// is consumed
+func (t *texParser) row(end string) string {
+	out := new(strings.Builder)
+	for {
+		t.skipSpace()
+		if t.pos >= len(t.src) {
+			return out.String()
+		}
+		if end != "" && t.at(end) {
+			t.pos += len(end)
+			return out.String()
+		}
+		if c := t.src[t.pos]; c == '}' || c == '&' || t.at(`\\`) || t.at(`\right`) || t.at(`\end`) {
+			// ends an enclosing group, cell or row
+			return out.String()
+		}
+		out.WriteString(t.scripted())
+	}
+}
+
+// whether `s` is at the current position, and if it is a command, not
+// just the start of a longer one, like `\right` of `\rightarrow`
+func (t *texParser) at(s string) bool {
+	if !strings.HasPrefix(t.src[t.pos:], s) {
+		return false
+	}
+	next := t.pos + len(s)
+	return !isLetter(s[len(s)-1]) || next >= len(t.src) || !isLetter(t.src[next])
+}
+
+// an element and its sub- and superscripts
+func (t *texParser) scripted() string {
+	base, limits := t.atom()
+	var sub, sup string
+	for {
+		t.skipSpace()
+		if t.pos >= len(t.src) {
+			break
+		}
+		if c := t.src[t.pos]; c == '_' && sub == "" {
+			t.pos++
+			sub = t.argument()
+		} else if c == '^' && sup == "" {
+			t.pos++
+			sup = t.argument()
+		} else if c == '\'' {
+			// primes are superscripts
+			t.pos++
+			sup += "<mo>′</mo>"
+		} else {
+			break
+		}
+	}
+	if limits && t.display {
+		switch {
+		case sub != "" && sup != "":
+			return "<munderover>" + base + wrap(sub) + wrap(sup) + "</munderover>"
+		case sub != "":
+			return "<munder>" + base + wrap(sub) + "</munder>"
+		case sup != "":
+			return "<mover>" + base + wrap(sup) + "</mover>"
+		}
+	}
+	switch {
+	case sub != "" && sup != "":
+		return "<msubsup>" + base + wrap(sub) + wrap(sup) + "</msubsup>"
+	case sub != "":
+		return "<msub>" + base + wrap(sub) + "</msub>"
+	case sup != "":
+		return "<msup>" + base + wrap(sup) + "</msup>"
+	}
+	return base
+}
+
+// the elements as a single one
+func wrap(elements string) string {
+	return "<mrow>" + elements + "</mrow>"
+}
+
+// the argument of a command or script: a group or a single element
+func (t *texParser) argument() string {
+	t.skipSpace()
+	if t.pos < len(t.src) && t.src[t.pos] == '{' {
+		t.pos++
+		return t.row("}")
+	}
+	if t.pos >= len(t.src) {
+		return ""
+	}
+	if t.src[t.pos] == '\\' {
+		e, _ := t.atom()
+		return e
+	}
+	// a single character, not a whole number, like `x^23` in LaTeX
+	r, size := utf8.DecodeRuneInString(t.src[t.pos:])
+	t.pos += size
+	return t.char(r)
+}
+
+// the text of a group, as written
+func (t *texParser) rawArgument() string {
+	t.skipSpace()
+	if t.pos >= len(t.src) || t.src[t.pos] != '{' {
+		if t.pos < len(t.src) {
+			t.pos++
+			return t.src[t.pos-1 : t.pos]
+		}
+		return ""
+	}
+	depth, start := 0, t.pos+1
+	for ; t.pos < len(t.src); t.pos++ {
+		switch t.src[t.pos] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				t.pos++
+				return t.src[start : t.pos-1]
+			}
+		}
+	}
+	return t.src[start:]
+}
+
+// the next element. Also says whether its scripts go under and over it in
+// display math, like the limits of a sum.
+func (t *texParser) atom() (string, bool) {
+	c := t.src[t.pos]
+	switch {
+	case c == '{':
+		t.pos++
+		return wrap(t.row("}")), false
+	case c == '\\':
+		return t.command()
+	case c >= '0' && c <= '9' || c == '.' && t.pos+1 < len(t.src) && t.src[t.pos+1] >= '0' && t.src[t.pos+1] <= '9':
+		start := t.pos
+		for t.pos < len(t.src) && (t.src[t.pos] >= '0' && t.src[t.pos] <= '9' || t.src[t.pos] == '.') {
+			t.pos++
+		}
+		return "<mn>" + t.src[start:t.pos] + "</mn>", false
+	}
+	r, size := utf8.DecodeRuneInString(t.src[t.pos:])
+	t.pos += size
+	return t.char(r), false
+}
+
+// a single character
+func (t *texParser) char(r rune) string {
+	switch {
+	case r >= '0' && r <= '9':
+		return "<mn>" + string(r) + "</mn>"
+	case unicode.IsLetter(r):
+		return "<mi>" + string(r) + "</mi>"
+	case r == '-':
+		return "<mo>−</mo>"
+	case r == '*':
+		return "<mo>∗</mo>"
+	}
+	return "<mo>" + html.EscapeString(string(r)) + "</mo>"
+}
+
+// the command at the current position, e.g. `\frac{a}{b}`
+func (t *texParser) command() (string, bool) {
+	t.pos++ // the backslash
+	if t.pos >= len(t.src) {
+		return "<mo>\\</mo>", false
+	}
+	start := t.pos
+	for t.pos < len(t.src) && isLetter(t.src[t.pos]) {
+		t.pos++
+	}
+	if t.pos == start {
+		// a symbol, like `\{` or `\,`
+		t.pos++
+	}
+	name := t.src[start:t.pos]
+
+	if s, ok := texSymbols[name]; ok {
+		return s, false
+	}
+	if s, ok := texOperators[name]; ok {
+		return "<mo>" + s + "</mo>", true
+	}
+	if texFunctions[name] {
+		return "<mi>" + name + "</mi>", name == "lim" || name == "max" || name == "min" || name == "sup" || name == "inf"
+	}
+	if accent, ok := texAccents[name]; ok {
+		return `<mover accent="true">` + wrap(t.argument()) + "<mo>" + accent + "</mo></mover>", false
+	}
+	switch name {
+	case "frac", "dfrac", "tfrac":
+		num := t.argument()
+		return "<mfrac>" + wrap(num) + wrap(t.argument()) + "</mfrac>", false
+	case "binom":
+		top := t.argument()
+		return `<mrow><mo>(</mo><mfrac linethickness="0">` + wrap(top) + wrap(t.argument()) + `</mfrac><mo>)</mo></mrow>`, false
+	case "sqrt":
+		t.skipSpace()
+		if t.pos < len(t.src) && t.src[t.pos] == '[' {
+			t.pos++
+			index := t.row("]")
+			return "<mroot>" + wrap(t.argument()) + wrap(index) + "</mroot>", false
+		}
+		return "<msqrt>" + t.argument() + "</msqrt>", false
+	case "text", "textrm", "mbox":
+		return "<mtext>" + html.EscapeString(t.rawArgument()) + "</mtext>", false
+	case "operatorname", "mathrm":
+		return `<mi mathvariant="normal">` + html.EscapeString(t.rawArgument()) + "</mi>", false
+	case "mathbf", "boldsymbol":
+		return `<mrow style="font-weight: bold">` + t.argument() + "</mrow>", false
+	case "mathbb", "mathcal":
+		return "<mi>" + html.EscapeString(mathAlphabet(name, t.rawArgument())) + "</mi>", false
+	case "left":
+		open := t.delimiter()
+		inner := t.row(`\right`)
+		return "<mrow>" + open + inner + t.delimiter() + "</mrow>", false
+	case "begin":
+		return t.environment(t.rawArgument()), false
+	}
+	return `<merror><mtext>\` + html.EscapeString(name) + "</mtext></merror>", false
+}
+
+// the delimiter after `\left` or `\right`
+func (t *texParser) delimiter() string {
+	t.skipSpace()
+	if t.pos >= len(t.src) {
+		return ""
+	}
+	if t.src[t.pos] == '.' {
+		t.pos++
+		return ""
+	}
+	e, _ := t.atom()
+	return e
+}
+
+// the environment `name`, up to its `\end`
+func (t *texParser) environment(name string) string {
+	var rows []string
+	for {
+		var cells []string
+		for {
+			cells = append(cells, "<mtd>"+t.row("")+"</mtd>")
+			if t.pos < len(t.src) && t.src[t.pos] == '&' {
+				t.pos++
+				continue
+			}
+			break
+		}
+		rows = append(rows, "<mtr>"+strings.Join(cells, "")+"</mtr>")
+		if t.at(`\\`) {
+			t.pos += 2
+			continue
+		}
+		// `\end{name}`, the end of the formula or a stray `}`
+		if t.at(`\end`) {
+			t.pos += len(`\end`)
+			t.rawArgument()
+		} else if t.pos < len(t.src) {
+			t.pos++
+		}
+		break
+	}
+	table := "<mtable>" + strings.Join(rows, "") + "</mtable>"
+	switch name {
+	case "pmatrix":
+		return "<mrow><mo>(</mo>" + table + "<mo>)</mo></mrow>"
+	case "bmatrix":
+		return "<mrow><mo>[</mo>" + table + "<mo>]</mo></mrow>"
+	case "vmatrix":
+		return "<mrow><mo>|</mo>" + table + "<mo>|</mo></mrow>"
+	case "cases":
+		return `<mrow><mo>{</mo><mtable columnalign="left left">` + strings.Join(rows, "") + "</mtable></mrow>"
+	case "aligned", "align", "align*":
+		return `<mtable columnalign="right left">` + strings.Join(rows, "") + "</mtable>"
+	}
+	return table
+}
+
+func (t *texParser) skipSpace() {
+	for t.pos < len(t.src) && isSpace(t.src[t.pos]) {
+		t.pos++
+	}
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// `text` in the double-struck (`mathbb`) or script (`mathcal`) alphabet
+func mathAlphabet(font, text string) string {
+	var first rune
+	var exceptions map[rune]rune
+	if font == "mathbb" {
+		first = 0x1D538
+		exceptions = map[rune]rune{'C': 'ℂ', 'H': 'ℍ', 'N': 'ℕ', 'P': 'ℙ', 'Q': 'ℚ', 'R': 'ℝ', 'Z': 'ℤ'}
+	} else {
+		first = 0x1D49C
+		exceptions = map[rune]rune{'B': 'ℬ', 'E': 'ℰ', 'F': 'ℱ', 'H': 'ℋ', 'I': 'ℐ', 'L': 'ℒ', 'M': 'ℳ', 'R': 'ℛ'}
+	}
+	out := new(strings.Builder)
+	for _, r := range strings.TrimSpace(text) {
+		switch {
+		case exceptions[r] != 0:
+			out.WriteRune(exceptions[r])
+		case r >= 'A' && r <= 'Z':
+			out.WriteRune(first + r - 'A')
+		default:
+			out.WriteRune(r)
+		}
+	}
+	return out.String()
+}
+
+// commands that are a single symbol
+var texSymbols = map[string]string{
+	"alpha": "<mi>α</mi>", "beta": "<mi>β</mi>", "gamma": "<mi>γ</mi>", "delta": "<mi>δ</mi>",
+	"epsilon": "<mi>ϵ</mi>", "varepsilon": "<mi>ε</mi>", "zeta": "<mi>ζ</mi>", "eta": "<mi>η</mi>",
+	"theta": "<mi>θ</mi>", "vartheta": "<mi>ϑ</mi>", "iota": "<mi>ι</mi>", "kappa": "<mi>κ</mi>",
+	"lambda": "<mi>λ</mi>", "mu": "<mi>μ</mi>", "nu": "<mi>ν</mi>", "xi": "<mi>ξ</mi>",
+	"pi": "<mi>π</mi>", "varpi": "<mi>ϖ</mi>", "rho": "<mi>ρ</mi>", "varrho": "<mi>ϱ</mi>",
+	"sigma": "<mi>σ</mi>", "varsigma": "<mi>ς</mi>", "tau": "<mi>τ</mi>", "upsilon": "<mi>υ</mi>",
+	"phi": "<mi>ϕ</mi>", "varphi": "<mi>φ</mi>", "chi": "<mi>χ</mi>", "psi": "<mi>ψ</mi>",
+	"omega": "<mi>ω</mi>",
+	"Gamma": `<mi mathvariant="normal">Γ</mi>`, "Delta": `<mi mathvariant="normal">Δ</mi>`,
+	"Theta": `<mi mathvariant="normal">Θ</mi>`, "Lambda": `<mi mathvariant="normal">Λ</mi>`,
+	"Xi": `<mi mathvariant="normal">Ξ</mi>`, "Pi": `<mi mathvariant="normal">Π</mi>`,
+	"Sigma": `<mi mathvariant="normal">Σ</mi>`, "Upsilon": `<mi mathvariant="normal">Υ</mi>`,
+	"Phi": `<mi mathvariant="normal">Φ</mi>`, "Psi": `<mi mathvariant="normal">Ψ</mi>`,
+	"Omega": `<mi mathvariant="normal">Ω</mi>`,
+
+	"infty": "<mi>∞</mi>", "partial": "<mi>∂</mi>", "nabla": "<mi>∇</mi>", "ell": "<mi>ℓ</mi>",
+	"emptyset": "<mi>∅</mi>", "hbar": "<mi>ℏ</mi>",
+
+	"pm": "<mo>±</mo>", "mp": "<mo>∓</mo>", "times": "<mo>×</mo>", "div": "<mo>÷</mo>",
+	"cdot": "<mo>⋅</mo>", "ast": "<mo>∗</mo>", "circ": "<mo>∘</mo>", "bullet": "<mo>∙</mo>",
+	"le": "<mo>≤</mo>", "leq": "<mo>≤</mo>", "ge": "<mo>≥</mo>", "geq": "<mo>≥</mo>",
+	"ne": "<mo>≠</mo>", "neq": "<mo>≠</mo>", "approx": "<mo>≈</mo>", "equiv": "<mo>≡</mo>",
+	"sim": "<mo>∼</mo>", "simeq": "<mo>≃</mo>", "propto": "<mo>∝</mo>", "ll": "<mo>≪</mo>",
+	"gg": "<mo>≫</mo>", "in": "<mo>∈</mo>", "notin": "<mo>∉</mo>", "ni": "<mo>∋</mo>",
+	"subset": "<mo>⊂</mo>", "subseteq": "<mo>⊆</mo>", "supset": "<mo>⊃</mo>", "supseteq": "<mo>⊇</mo>",
+	"cup": "<mo>∪</mo>", "cap": "<mo>∩</mo>", "setminus": "<mo>∖</mo>", "land": "<mo>∧</mo>",
+	"wedge": "<mo>∧</mo>", "lor": "<mo>∨</mo>", "vee": "<mo>∨</mo>", "neg": "<mo>¬</mo>",
+	"lnot": "<mo>¬</mo>", "forall": "<mo>∀</mo>", "exists": "<mo>∃</mo>", "mid": "<mo>∣</mo>",
+	"to": "<mo>→</mo>", "rightarrow": "<mo>→</mo>", "leftarrow": "<mo>←</mo>", "gets": "<mo>←</mo>",
+	"Rightarrow": "<mo>⇒</mo>", "Leftarrow": "<mo>⇐</mo>", "leftrightarrow": "<mo>↔</mo>",
+	"Leftrightarrow": "<mo>⇔</mo>", "iff": "<mo>⟺</mo>", "implies": "<mo>⟹</mo>", "mapsto": "<mo>↦</mo>",
+	"ldots": "<mo>…</mo>", "dots": "<mo>…</mo>", "cdots": "<mo>⋯</mo>", "vdots": "<mo>⋮</mo>",
+	"ddots": "<mo>⋱</mo>", "lfloor": "<mo>⌊</mo>", "rfloor": "<mo>⌋</mo>", "lceil": "<mo>⌈</mo>",
+	"rceil": "<mo>⌉</mo>", "langle": "<mo>⟨</mo>", "rangle": "<mo>⟩</mo>",
+
+	"{": "<mo>{</mo>", "}": "<mo>}</mo>", "|": "<mo>‖</mo>", "%": "<mo>%</mo>", "$": "<mo>$</mo>",
+	"#": "<mo>#</mo>", "&": "<mo>&amp;</mo>", "_": "<mo>_</mo>",
+	",": `<mspace width="0.1667em"/>`, ":": `<mspace width="0.2222em"/>`, ";": `<mspace width="0.2778em"/>`,
+	" ": `<mspace width="0.3333em"/>`, "quad": `<mspace width="1em"/>`, "qquad": `<mspace width="2em"/>`,
+	"!": "",
+}
+
+// large operators, whose limits go under and over them in display math
+var texOperators = map[string]string{
+	"sum": "∑", "prod": "∏", "coprod": "∐", "int": "∫", "iint": "∬", "oint": "∮",
+	"bigcup": "⋃", "bigcap": "⋂", "bigvee": "⋁", "bigwedge": "⋀",
+}
+
+// named functions, set upright
+var texFunctions = map[string]bool{
+	"sin": true, "cos": true, "tan": true, "cot": true, "sec": true, "csc": true,
+	"arcsin": true, "arccos": true, "arctan": true, "sinh": true, "cosh": true, "tanh": true,
+	"log": true, "ln": true, "lg": true, "exp": true, "lim": true, "max": true, "min": true,
+	"sup": true, "inf": true, "det": true, "dim": true, "gcd": true, "deg": true, "arg": true,
+	"mod": true, "bmod": true, "Pr": true,
+}
+
+// accents, by the command placing them over their argument
+var texAccents = map[string]string{
+	"hat": "^", "widehat": "^", "bar": "‾", "overline": "‾", "vec": "→", "tilde": "~",
+	"widetilde": "~", "dot": "˙", "ddot": "¨",
+}
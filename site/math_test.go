@@ -0,0 +1,72 @@
+package site
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMathDelimiters(t *testing.T) {
+	md := newMarkdown([]string{"math"}, nil)
+	tests := []struct {
+		note string
+		// the TeX of each formula found, in order
+		want []string
+	}{
+		{`the rate $\lambda$ of arrivals`, []string{`\lambda`}},
+		{`$x$, then $y$.`, []string{"x", "y"}},
+		{`$$\sum_i x_i$$`, []string{`\sum_i x_i`}},
+		{"$$\na + b\n$$", []string{"a + b"}},
+		{`from $5 to $10`, nil},
+		{`costs $5 and $a$ more`, []string{"a"}},
+		{`$ x$ is not math`, nil},
+		{`$HOME/$USER`, nil},
+		{`$(OBJS):$(SRCS)`, nil},
+		{`$(CC) -o $@ $^`, nil},
+		{`an escaped \$ sign`, nil},
+		{`an $a\$b$ escape`, []string{`a\$b`}},
+	}
+	for _, tt := range tests {
+		html := string(renderMarkdown(md, "test", []byte(tt.note), nil))
+		var got []string
+		for _, part := range strings.Split(html, `<annotation encoding="application/x-tex">`)[1:] {
+			got = append(got, part[:strings.Index(part, "</annotation>")])
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%q: got formulas %q, want %q\n%v", tt.note, got, tt.want, html)
+		}
+	}
+}
+
+func TestMathOffByDefault(t *testing.T) {
+	md := newMarkdown(defaultMarkdownExtensions, nil)
+	if html := string(renderMarkdown(md, "test", []byte(`set $x$ and $y$`), nil)); strings.Contains(html, "<math") {
+		t.Errorf("math rendered without the math extension: %v", html)
+	}
+}
+
+func TestMathML(t *testing.T) {
+	tests := []struct {
+		tex, want string
+	}{
+		{`x^2`, `<msup><mi>x</mi><mrow><mn>2</mn></mrow></msup>`},
+		{`\alpha_i`, `<msub><mi>α</mi><mrow><mi>i</mi></mrow></msub>`},
+		{`\frac{a}{b}`, `<mfrac><mrow><mi>a</mi></mrow><mrow><mi>b</mi></mrow></mfrac>`},
+		{`\sqrt{x}`, `<msqrt><mi>x</mi></msqrt>`},
+		{`\text{if } x`, `<mtext>if </mtext><mi>x</mi>`},
+		{`\mathbb{R}`, `<mi>ℝ</mi>`},
+		{`\left( x \right)`, `<mrow><mo>(</mo><mi>x</mi><mo>)</mo></mrow>`},
+		// `\right` must not be taken for the start of `\rightarrow`
+		{`x \rightarrow y`, `<mi>x</mi><mo>→</mo><mi>y</mi>`},
+		{`\begin{pmatrix} a & b \\ c & d \end{pmatrix}`, `<mrow><mo>(</mo><mtable><mtr><mtd><mi>a</mi></mtd><mtd><mi>b</mi></mtd></mtr><mtr><mtd><mi>c</mi></mtd><mtd><mi>d</mi></mtd></mtr></mtable><mo>)</mo></mrow>`},
+		{`\foo`, `<merror><mtext>\foo</mtext></merror>`},
+	}
+	for _, tt := range tests {
+		got := mathML(tt.tex, false)
+		if !strings.Contains(got, "<semantics><mrow>"+tt.want+"</mrow><annotation") {
+			t.Errorf("%q: got %v, want %v", tt.tex, got, tt.want)
+		}
+	}
+	if got := mathML("x", true); !strings.Contains(got, `display="block"`) {
+		t.Errorf("display math: got %v", got)
+	}
+}
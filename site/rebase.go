@@ -0,0 +1,285 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// ## Rebasing notes
+// When the documented file changes upstream, `Rebase` carries the notes of a
+// snapshot over to the new code with a three-way merge. The common base is
+// the upstream file at the snapshot's own commit: the snapshot is that file
+// plus the notes, and the new revision is that file plus the upstream
+// changes. Where only one side changed a region of the base, that side wins.
+// Notes anchored to code that changed upstream go right before the new code.
+// Where both sides rewrote the same lines of the base, e.g. an upstream
+// comment edited in the notes and upstream alike, both are kept and flagged
+// as a conflict. Notes whose code is gone entirely are kept too, next to
+// where the code used to be, and flagged for review.
+
+// the line added above notes whose code disappeared
+const orphanWarning = "lazylit rebase: the code this note described was removed or rewritten; review this note."
+
+// the line added above lines that changed both in the notes and upstream
+const conflictWarning = "lazylit rebase: these lines changed both here and upstream; merge them by hand and review this note."
+
+// the artifact file name without its date suffix, e.g. `Makefile` for
+// `Makefile.jul_18_2020`
+func StripDateSuffix(name string) string {
+	return stripDateSuffix(name)
+}
+
+// the snapshot `a` with its notes moved onto `code`, a newer revision of the
+// source file, and `headers` replacing the values of its headers. `base` is
+// the source file at the snapshot's own commit. Also returns the 1-based
+// lines of the notes whose code disappeared, and of the conflicts.
+func (s *Site) Rebase(a Snapshot, base, code []byte, headers map[string]string) ([]byte, []int, []int, error) {
+	if a.notesPath != "" {
+		return nil, nil, nil, fmt.Errorf("%v: can't rebase sidecar notes; update their line ranges by hand", a.DocFileName)
+	}
+	data, err := fs.ReadFile(s.b.src, a.path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	lines := strings.Split(string(data), "\n")
+	// the snapshot without its headers and the blank line after them
+	ours := fileLines(strings.Join(lines[a.FirstNonHeaderLine:], "\n"))
+	if len(ours) > 0 && ours[0] == "" {
+		ours = ours[1:]
+	}
+	m := newMerge(fileLines(string(base)), ours, fileLines(string(code)))
+
+	marker := s.b.marker(a)
+	out := new(bytes.Buffer)
+	s.writeHeaders(out, a, lines[:a.FirstNonHeaderLine], headers)
+	out.WriteString("\n")
+	lineNo := strings.Count(out.String(), "\n")
+	var orphans, conflicts []int
+	for _, line := range m.merged() {
+		switch line.flag {
+		case orphanWarning:
+			orphans = append(orphans, lineNo+1)
+		case conflictWarning:
+			conflicts = append(conflicts, lineNo+1)
+		}
+		if line.flag != "" {
+			out.WriteString(strings.TrimSpace(a.language.headerStart+marker+" "+line.flag+" "+a.language.headerEnd) + "\n")
+			lineNo++
+		}
+		out.WriteString(line.text + "\n")
+		lineNo++
+	}
+	return out.Bytes(), orphans, conflicts, nil
+}
+
+// the lines of a file, without an empty one after the newline ending it
+func fileLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// ### The merge
+// A `merge` holds the base, our lines (the snapshot's) and theirs (the new
+// revision's), and where each base line is on either side, or -1 where it
+// was removed or rewritten. Lines are compared without their surrounding
+// whitespace, so re-indented code still matches.
+type merge struct {
+	base, ours, theirs []string
+	// index of each base line in ours and theirs
+	inOurs, inTheirs []int
+	// index of each of our lines in the base; -1 for notes
+	ourBase []int
+}
+
+func newMerge(base, ours, theirs []string) *merge {
+	m := &merge{base: base, ours: ours, theirs: theirs}
+	m.inOurs = matches(base, ours)
+	m.inTheirs = matches(base, theirs)
+	m.ourBase = make([]int, len(ours))
+	for j := range m.ourBase {
+		m.ourBase[j] = -1
+	}
+	for i, j := range m.inOurs {
+		if j >= 0 {
+			m.ourBase[j] = i
+		}
+	}
+	return m
+}
+
+// where each line of `a` is in `b`, or -1
+func matches(a, b []string) []int {
+	at := make([]int, len(a))
+	for i := range at {
+		at[i] = -1
+	}
+	for _, op := range diffLines(trimLines(a), trimLines(b)) {
+		if op.kind == opSame {
+			at[op.old] = op.new
+		}
+	}
+	return at
+}
+
+// a line of the merged file, with the warning to write above it, if any
+type mergedLine struct {
+	text, flag string
+}
+
+// the merged file. The base lines still on both sides split it into
+// regions, which are merged one at a time.
+func (m *merge) merged() []mergedLine {
+	var out []mergedLine
+	i, j, k := 0, 0, 0
+	for stable := 0; stable <= len(m.base); stable++ {
+		if stable < len(m.base) && (m.inOurs[stable] < 0 || m.inTheirs[stable] < 0) {
+			continue
+		}
+		ourEnd, theirEnd := len(m.ours), len(m.theirs)
+		if stable < len(m.base) {
+			ourEnd, theirEnd = m.inOurs[stable], m.inTheirs[stable]
+		}
+		out = append(out, m.region(i, stable, j, ourEnd, k, theirEnd)...)
+		if stable < len(m.base) {
+			// the new code, as it is now
+			out = append(out, mergedLine{text: m.theirs[theirEnd]})
+		}
+		i, j, k = stable+1, ourEnd+1, theirEnd+1
+	}
+	return out
+}
+
+// merge the base lines `i` to `iEnd` with our lines `j` to `jEnd` and their
+// lines `k` to `kEnd`. None of the base lines is on both sides.
+func (m *merge) region(i, iEnd, j, jEnd, k, kEnd int) []mergedLine {
+	oursChanged := jEnd-j != iEnd-i
+	for x := i; x < iEnd && !oursChanged; x++ {
+		oursChanged = m.inOurs[x] < 0
+	}
+	theirsChanged := kEnd-k != iEnd-i
+	for x := i; x < iEnd && !theirsChanged; x++ {
+		theirsChanged = m.inTheirs[x] < 0
+	}
+
+	var out []mergedLine
+	if !oursChanged {
+		for _, line := range m.theirs[k:kEnd] {
+			out = append(out, mergedLine{text: line})
+		}
+		return out
+	}
+	if !theirsChanged {
+		return m.notes(j, jEnd, false)
+	}
+
+	rewritten := false
+	for x := i; x < iEnd; x++ {
+		rewritten = rewritten || m.inOurs[x] < 0
+	}
+	if rewritten {
+		// both sides rewrote the base: keep what we added, then theirs
+		for x := j; x < jEnd; x++ {
+			if m.ourBase[x] < 0 {
+				out = append(out, mergedLine{text: m.ours[x]})
+			}
+		}
+		if len(out) > 0 {
+			out[0].flag = conflictWarning
+		}
+		for _, line := range m.theirs[k:kEnd] {
+			out = append(out, mergedLine{text: line})
+		}
+		return out
+	}
+
+	// we only added notes. Those after the region's last base line are on
+	// the code after it, which is still there; the rest go before theirs.
+	tail := j
+	for x := i; x < iEnd; x++ {
+		tail = m.inOurs[x] + 1
+	}
+	out = append(out, m.notes(j, tail, true)...)
+	for _, line := range m.theirs[k:kEnd] {
+		out = append(out, mergedLine{text: line})
+	}
+	return append(out, m.notes(tail, jEnd, false)...)
+}
+
+// our lines `j` to `jEnd`: just the notes if `notesOnly`, as the code is
+// theirs. Each run of notes is flagged if none of the code after it is
+// still there.
+func (m *merge) notes(j, jEnd int, notesOnly bool) []mergedLine {
+	var out []mergedLine
+	for x := j; x < jEnd; x++ {
+		if m.ourBase[x] >= 0 {
+			if !notesOnly {
+				out = append(out, mergedLine{text: m.ours[x]})
+			}
+			continue
+		}
+		line := mergedLine{text: m.ours[x]}
+		if x == j || m.ourBase[x-1] >= 0 {
+			if m.orphaned(x) {
+				line.flag = orphanWarning
+			}
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+// whether the note starting on our line `x` lost all of its code: the
+// lines from the base after it, up to the next note
+func (m *merge) orphaned(x int) bool {
+	for x < len(m.ours) && m.ourBase[x] < 0 {
+		x++
+	}
+	code := false
+	for ; x < len(m.ours) && m.ourBase[x] >= 0; x++ {
+		base := m.ourBase[x]
+		// blank lines don't count, they survive anything
+		if strings.TrimSpace(m.base[base]) == "" {
+			continue
+		}
+		if m.inTheirs[base] >= 0 {
+			return false
+		}
+		code = true
+	}
+	return code
+}
+
+// write the header lines of `a`, `old`, with the values in `headers`
+// replaced or added
+func (s *Site) writeHeaders(out *bytes.Buffer, a Snapshot, old []string, headers map[string]string) {
+	done := make(map[string]bool)
+	for _, line := range old {
+		m := a.language.headerParser.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		if value, ok := headers[m[1]]; ok {
+			line = strings.TrimSuffix(a.language.Header(m[1], value), "\n")
+			done[m[1]] = true
+		}
+		out.WriteString(line + "\n")
+	}
+	for _, h := range HeaderNames {
+		if value, ok := headers[h]; ok && !done[h] {
+			out.WriteString(a.language.Header(h, value))
+		}
+	}
+}
+
+// `lines` without surrounding whitespace, so re-indented code still matches
+func trimLines(lines []string) []string {
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		out[i] = strings.TrimSpace(line)
+	}
+	return out
+}
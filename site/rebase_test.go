@@ -0,0 +1,149 @@
+package site
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// the site of the single artifact `tool/tool.jan_1_2020.go`, whose body is
+// `body`, read from memory
+func scanTestSite(t *testing.T, body string, config *Config) (*Site, Snapshot) {
+	t.Helper()
+	src := fstest.MapFS{
+		"tool/tool.jan_1_2020.go": {Data: []byte(testHeaders + "\n" + body)},
+	}
+	s, err := Scan(context.Background(), Options{Src: src, SrcDir: "artifacts", Config: config})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Problems) > 0 {
+		t.Fatalf("problems: %v", s.Problems)
+	}
+	return s, s.Artifacts[0].Snapshots[0]
+}
+
+func TestRebase(t *testing.T) {
+	tests := []struct {
+		name string
+		// the snapshot's body, the file at its commit and at the new one
+		body, base, code string
+		want             string
+		// the lines of the rebased artifact flagged for review
+		orphans, conflicts []int
+	}{
+		{
+			"unchanged",
+			"// note\nfunc a() {}\n",
+			"func a() {}\n",
+			"func a() {}\n",
+			"// note\nfunc a() {}\n",
+			nil, nil,
+		},
+		{
+			"code moved down",
+			"// about a\nfunc a() {}\n\n// about b\nfunc b() {}\n",
+			"func a() {}\n\nfunc b() {}\n",
+			"import \"x\"\n\nfunc a() {}\n\nfunc b() {}\n",
+			"import \"x\"\n\n// about a\nfunc a() {}\n\n// about b\nfunc b() {}\n",
+			nil, nil,
+		},
+		{
+			"code edited in place",
+			"// about a\nfunc a() {\n\treturn 1\n}\n",
+			"func a() {\n\treturn 1\n}\n",
+			"func a() {\n\treturn 2\n}\n",
+			"// about a\nfunc a() {\n\treturn 2\n}\n",
+			nil, nil,
+		},
+		{
+			// the note goes right before the code that took its code's place
+			"code rewritten",
+			"x := 1\n// about y\ny := 2\nz := 3\n",
+			"x := 1\ny := 2\nz := 3\n",
+			"x := 1\ny := 4\nw := 5\nz := 3\n",
+			"x := 1\n// about y\ny := 4\nw := 5\nz := 3\n",
+			nil, nil,
+		},
+		{
+			"code removed",
+			"// about a\nfunc a() {}\n// about b\nfunc b() {}\n",
+			"func a() {}\nfunc b() {}\n",
+			"func b() {}\n",
+			"// " + orphanWarning + "\n// about a\n// about b\nfunc b() {}\n",
+			[]int{7}, nil,
+		},
+		{
+			// upstream comments are in the base, so they aren't notes
+			"upstream comment kept",
+			"// Foo does it\n// and this is why\nfunc Foo() {}\n",
+			"// Foo does it\nfunc Foo() {}\n",
+			"package x\n\n// Foo does it\nfunc Foo() {}\n",
+			"package x\n\n// Foo does it\n// and this is why\nfunc Foo() {}\n",
+			nil, nil,
+		},
+		{
+			"upstream comment reworded",
+			"// Foo does it\n// and this is why\nfunc Foo() {}\n",
+			"// Foo does it\nfunc Foo() {}\n",
+			"// Foo does it now\nfunc Foo() {}\n",
+			"// Foo does it now\n// and this is why\nfunc Foo() {}\n",
+			nil, nil,
+		},
+		{
+			// the note rewrote the upstream comment, which changed upstream
+			// too
+			"conflict",
+			"// Foo does it, slowly\nfunc Foo() {}\n",
+			"// Foo does it\nfunc Foo() {}\n",
+			"// Foo does it now\nfunc Foo() {}\n",
+			"// " + conflictWarning + "\n// Foo does it, slowly\n// Foo does it now\nfunc Foo() {}\n",
+			nil, []int{7},
+		},
+		{
+			// a line the note removed stays removed
+			"upstream line dropped in the notes",
+			"// mine\nfunc Foo() {}\n",
+			"// theirs\nfunc Foo() {}\n",
+			"// theirs\nfunc Foo() {}\nfunc Bar() {}\n",
+			"// mine\nfunc Foo() {}\nfunc Bar() {}\n",
+			nil, nil,
+		},
+	}
+	for _, tt := range tests {
+		s, a := scanTestSite(t, tt.body, nil)
+		got, orphans, conflicts, err := s.Rebase(a, []byte(tt.base), []byte(tt.code), map[string]string{"Commit": "2222222"})
+		if err != nil {
+			t.Errorf("%v: %v", tt.name, err)
+			continue
+		}
+		want := strings.Replace(testHeaders, "1111111", "2222222", 1) + "\n" + tt.want
+		if string(got) != want {
+			t.Errorf("%v:\ngot\n%v\nwant\n%v", tt.name, string(got), want)
+		}
+		if fmt.Sprint(orphans) != fmt.Sprint(tt.orphans) {
+			t.Errorf("%v: got orphans %v, want %v", tt.name, orphans, tt.orphans)
+		}
+		if fmt.Sprint(conflicts) != fmt.Sprint(tt.conflicts) {
+			t.Errorf("%v: got conflicts %v, want %v", tt.name, conflicts, tt.conflicts)
+		}
+	}
+}
+
+func TestRebaseMarked(t *testing.T) {
+	config := DefaultConfig()
+	config.Notes = notesMarked
+	s, a := scanTestSite(t, "//> a note\n// Foo does it\nfunc Foo() {}\n", config)
+	code := []byte("// Foo does it\nfunc Foo() {}\n")
+	got, _, _, err := s.Rebase(a, code, code, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the upstream comment is code, so the note goes above it
+	want := testHeaders + "\n//> a note\n// Foo does it\nfunc Foo() {}\n"
+	if string(got) != want {
+		t.Errorf("got\n%v\nwant\n%v", string(got), want)
+	}
+}
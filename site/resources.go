@@ -0,0 +1,1016 @@
+package site
+
+var Css = `
+/*--------------------- Layout and Typography ----------------------------*/
+body {
+  font-family: 'Palatino Linotype', 'Book Antiqua', Palatino, FreeSerif, serif;
+  font-size: 15px;
+  line-height: 22px;
+  color: var(--text);
+  background: var(--page);
+  margin: 0; padding: 0;
+}
+hr {
+    border: 0;
+    border-top: 1px solid var(--muted);
+    margin-bottom: 1rem;
+}
+footer {
+    color: var(--muted);
+}
+a {
+  color: var(--link);
+}
+  a:visited {
+    color: var(--link);
+  }
+p {
+  margin: 0 0 15px 0;
+}
+p.footnote {
+    font-size: 0.6rem;
+}
+h1, h2, h3, h4, h5, h6 {
+  margin: 0px 0 15px 0;
+}
+  h1 {
+    margin-top: 40px;
+  }
+#container {
+  position: relative;
+}
+#background {
+  position: fixed;
+  top: 0; left: 525px; right: 0; bottom: 0;
+  background: var(--code);
+  border-left: 1px solid var(--rule);
+  z-index: -1;
+}
+#content {
+    padding: 10px 25px 1px 50px;
+    width: 465px;
+}
+  #content.wide {
+    width: auto;
+    max-width: 960px;
+  }
+.staleness {
+  font: 10px Arial;
+  text-transform: uppercase;
+  white-space: nowrap;
+  padding: 2px 6px;
+  border-radius: 3px;
+  color: white;
+  background: #BC7A00;
+}
+  .staleness.current {
+    background: #00A000;
+  }
+.diagram {
+  margin: 0 0 15px;
+  text-align: center;
+}
+  .diagram svg {
+    max-width: 100%;
+    height: auto;
+  }
+form.search {
+  position: relative;
+  margin: 15px 0 0;
+}
+  form.search input {
+    width: 100%;
+    box-sizing: border-box;
+    padding: 4px 8px;
+    font: 13px Arial;
+    color: var(--text);
+    background: var(--page);
+    border: 1px solid var(--input);
+    border-radius: 3px;
+  }
+  #search-results {
+    position: absolute;
+    z-index: 10;
+    left: 0; right: 0;
+    max-height: 400px;
+    overflow-y: auto;
+    margin: 2px 0 0; padding: 0;
+    list-style: none;
+    text-align: left;
+    background: var(--panel);
+    -webkit-box-shadow: 0 0 25px var(--shadow); -moz-box-shadow: 0 0 25px var(--shadow);
+  }
+    #search-results:empty {
+      display: none;
+    }
+    #search-results li {
+      padding: 5px 10px;
+      border-bottom: 1px solid var(--rule);
+    }
+    #search-results a {
+      display: block;
+      text-decoration: none;
+      font-weight: bold;
+    }
+    #search-results .date {
+      font: 10px Arial;
+      text-transform: uppercase;
+      color: var(--muted);
+    }
+    #search-results p {
+      margin: 0;
+      font-size: 13px;
+      line-height: 18px;
+    }
+form.filter {
+  display: flex;
+  margin: 15px 0;
+}
+  form.filter[hidden], #artifact-none[hidden] {
+    display: none;
+  }
+  form.filter input, form.filter select {
+    padding: 4px 8px;
+    font: 13px Arial;
+    color: var(--text);
+    background: var(--page);
+    border: 1px solid var(--input);
+    border-radius: 3px;
+  }
+    form.filter input {
+      flex: 1;
+      min-width: 0;
+    }
+    form.filter select {
+      margin-left: 10px;
+    }
+table.artifacts {
+  width: 100%;
+  border-collapse: collapse;
+  margin: 0 0 15px;
+}
+  table.artifacts th {
+    padding: 5px 8px;
+    text-align: left;
+    border-bottom: 1px solid var(--rule);
+  }
+    table.artifacts th button {
+      padding: 0;
+      font: 10px Arial;
+      text-transform: uppercase;
+      color: var(--muted);
+      background: none;
+      border: 0;
+      cursor: pointer;
+    }
+      table.artifacts th[aria-sort="ascending"] button:after {
+        content: " \25B2";
+      }
+      table.artifacts th[aria-sort="descending"] button:after {
+        content: " \25BC";
+      }
+  table.artifacts td {
+    padding: 8px;
+    vertical-align: top;
+    border-bottom: 1px solid var(--rule);
+  }
+    table.artifacts .number {
+      text-align: right;
+    }
+    table.artifacts .summary {
+      font-size: 13px;
+      line-height: 18px;
+      color: var(--muted);
+    }
+    table.artifacts .tag {
+      display: inline-block;
+      margin: 4px 4px 0 0;
+      padding: 1px 6px;
+      font: 10px Arial;
+      text-transform: uppercase;
+      background: var(--hover);
+      border: 1px solid var(--rule);
+      border-radius: 3px;
+      cursor: pointer;
+    }
+#jump_to, #jump_page, #theme_toggle {
+  background: var(--panel);
+  -webkit-box-shadow: 0 0 25px var(--shadow); -moz-box-shadow: 0 0 25px var(--shadow);
+  -webkit-border-bottom-left-radius: 5px; -moz-border-radius-bottomleft: 5px;
+  font: 10px Arial;
+  text-transform: uppercase;
+  cursor: pointer;
+  text-align: right;
+}
+#jump_to, #jump_wrapper {
+  position: fixed;
+  right: 0; top: 0;
+  padding: 5px 10px;
+}
+  #jump_wrapper {
+    padding: 0;
+    display: none;
+  }
+    #jump_to:hover #jump_wrapper, #jump_to:focus-within #jump_wrapper {
+      display: block;
+    }
+    #jump_page {
+      padding: 5px 0 3px;
+      margin: 0 0 25px 25px;
+    }
+      #jump_page .source {
+        display: block;
+        padding: 5px 10px;
+        text-decoration: none;
+        border-top: 1px solid var(--rule);
+      }
+        #jump_page .source:hover {
+          background: var(--hover);
+        }
+        #jump_page .source:first-child {
+        }
+#theme_toggle {
+  position: fixed;
+  right: 0; bottom: 0;
+  padding: 5px 10px;
+  border: 0;
+  color: var(--text);
+  -webkit-border-top-left-radius: 5px; -moz-border-radius-topleft: 5px;
+}
+th {
+    font-weight: normal;
+}
+#container > table {
+  width: 100%;
+  table-layout: fixed;
+}
+table td {
+  border: 0;
+  outline: 0;
+}
+  td.docs, th.docs {
+    width: 450px;
+    max-width: 450px;
+    min-width: 450px;
+    min-height: 5px;
+    padding: 10px 25px 1px 50px;
+    overflow-x: hidden;
+    vertical-align: top;
+    text-align: left;
+  }
+    .docs pre {
+      margin: 15px 0 15px;
+      padding-left: 15px;
+      overflow-x: auto;
+    }
+    .docs img {
+      max-width: 100%;
+    }
+    .docs p tt, .docs p code {
+      background: var(--inline-code);
+      border: 1px solid var(--input);
+      font-size: 12px;
+      padding: 0 0.2em;
+    }
+    .pilwrap {
+      position: relative;
+    }
+      .pilcrow {
+        font: 12px Arial;
+        text-decoration: none;
+        color: var(--muted);
+        position: absolute;
+        top: 3px; left: -20px;
+        padding: 1px 2px;
+        opacity: 0;
+        -webkit-transition: opacity 0.2s linear;
+      }
+        td.docs:hover .pilcrow {
+          opacity: 1;
+        }
+  td.code, th.code {
+    padding: 14px 15px 16px 25px;
+    vertical-align: top;
+    background: var(--code);
+    border-left: 1px solid var(--rule);
+  }
+    td.code .highlight {
+      overflow-x: auto;
+    }
+    pre, tt, code {
+      font-size: 12px; line-height: 18px;
+      font-family: Menlo, Monaco, Consolas, "Lucida Console", monospace;
+      margin: 0; padding: 0;
+    }
+
+
+/*---------------------- Diffs -------------------------------------------*/
+#diff {
+  padding: 10px 25px 25px 50px;
+}
+  #diff .note {
+    border-left: 4px solid var(--rule);
+    padding: 0 15px;
+    margin: 15px 0;
+  }
+    #diff .note.added {
+      border-color: #00A000;
+    }
+    #diff .note.removed {
+      border-color: #A00000;
+    }
+    #diff .note.edited {
+      border-color: #BC7A00;
+    }
+    #diff .note .kind {
+      font: 10px Arial;
+      text-transform: uppercase;
+      color: var(--muted);
+    }
+    #diff .note .old {
+      text-decoration: line-through;
+      color: var(--muted);
+    }
+  table.code-diff {
+    width: 100%;
+    table-layout: fixed;
+    background: var(--code);
+    border: 1px solid var(--rule);
+  }
+    table.code-diff td {
+      vertical-align: top;
+      padding: 0 10px;
+      white-space: pre-wrap;
+    }
+    table.code-diff tr.removed td.old, table.code-diff tr.changed td.old {
+      background: rgba(255, 0, 0, 0.15);
+    }
+    table.code-diff tr.added td.new, table.code-diff tr.changed td.new {
+      background: rgba(0, 160, 0, 0.15);
+    }
+    table.code-diff tr.skip td {
+      text-align: center;
+      color: var(--muted);
+      background: var(--hover);
+    }
+
+
+/*---------------------- Syntax Highlighting -----------------------------*/
+td.code .lineno {
+  display: inline-block;
+  width: 3em;
+  margin: 0 1em 0 -1em;
+  padding: 0;
+  text-align: right;
+  background: none;
+  color: var(--muted);
+  text-decoration: none;
+  -webkit-user-select: none; -moz-user-select: none; user-select: none;
+}
+  td.code a.lineno:hover, td.code a.lineno:target {
+    color: var(--link);
+    text-decoration: underline;
+  }
+.highlight .err {
+  border: 1px solid #FF0000;
+}
+
+
+/*---------------------- Narrow screens ----------------------------------*/
+/* Phones and split-screen windows: each section's notes stack above its
+   code, and the revisions menu opens as a drawer. */
+@media (max-width: 960px) {
+  #background {
+    display: none;
+  }
+  #content {
+    width: auto;
+    padding: 10px 15px 1px;
+  }
+  #diff {
+    padding: 10px 15px 25px;
+  }
+  #container > table, #container > table thead, #container > table tbody,
+  #container > table tr {
+    display: block;
+  }
+  td.docs, th.docs, td.code {
+    display: block;
+    width: auto;
+    max-width: none;
+    min-width: 0;
+  }
+    td.docs, th.docs {
+      padding: 10px 15px 1px;
+    }
+      th.docs {
+        padding-top: 35px;
+      }
+      .pilcrow {
+        display: none;
+      }
+    th.code {
+      display: none;
+    }
+    td.code {
+      padding: 10px 15px;
+      border-left: 0;
+      border-top: 1px solid var(--rule);
+      border-bottom: 1px solid var(--rule);
+    }
+  h1 {
+    margin-top: 20px;
+  }
+  #jump_to {
+    padding: 8px 12px;
+    font-size: 12px;
+  }
+    #jump_wrapper {
+      bottom: 0;
+      width: 80%;
+      max-width: 320px;
+      overflow-y: auto;
+      background: var(--panel);
+      -webkit-box-shadow: 0 0 25px var(--shadow); -moz-box-shadow: 0 0 25px var(--shadow);
+    }
+      #jump_page {
+        margin: 0;
+        padding: 0;
+        text-align: left;
+        -webkit-box-shadow: none; -moz-box-shadow: none;
+        -webkit-border-bottom-left-radius: 0; -moz-border-radius-bottomleft: 0;
+      }
+        #jump_page .source {
+          padding: 12px 16px;
+          font-size: 13px;
+        }
+}
+@media (max-width: 600px) {
+  table.artifacts .optional {
+    display: none;
+  }
+}
+`
+
+// the partial at the top of every page
+var HEADER_HTML = `
+{{- /* The top of every page, e.g. a company header. */ -}}
+{{- if .Config.DarkStyle }}
+  <button id="theme_toggle" type="button">Dark pages</button>
+{{- end -}}
+`
+
+// the partial at the bottom of every page
+var FOOTER_HTML = `
+{{- /* The bottom of every page, e.g. a company footer. */ -}}
+`
+
+// the menu of a snapshot's other revisions and diffs
+var REVISIONS_HTML = `
+{{- if .Multiple }}
+      <div id="jump_to" tabindex="0" aria-haspopup="true">
+        Other revisions &hellip;
+        <div id="jump_wrapper">
+          <div id="jump_page">
+              {{ range .OtherRevisions }}
+              <a class="source" href="{{ .Destination | base }}">
+                  {{ .CommitDateString }}
+              </a>
+              {{ end }}
+              {{- range .Diffs }}
+              <a class="source" href="{{ .Destination | base }}">
+                  Changes {{ .Older.CommitDateString }} &rarr; {{ .Newer.CommitDateString }}
+              </a>
+              {{- end }}
+          </div>
+        </div>
+      </div>
+    {{ end -}}
+`
+
+var ABOUT_HTML = `
+<!DOCTYPE html>
+
+<html>
+<head>
+    <title>{{ with .Config.Title }}{{ . }}{{ else }}About lazylit{{ end }}</title>
+  <meta http-equiv="content-type" content="text/html; charset=UTF-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">{{ with .Config.BaseURL }}
+  <link rel="canonical" href="{{ . }}/" />{{ end }}
+  <link rel="stylesheet" media="all" href="{{ .Root }}gocco.css" />{{ if .Config.DarkStyle }}
+  <script src="{{ .Root }}theme.js"></script>{{ end }}
+</head>
+
+<body>
+  {{- template "header.html" . }}
+  <div id="container">
+    <div id="content" class="wide">
+        <form class="search" role="search" onsubmit="return false">
+            <input type="search" id="search" placeholder="Search notes and code" autocomplete="off" data-root="{{ .Root }}">
+            <ol id="search-results"></ol>
+        </form>
+        {{- with .Config.Title }}
+        <h1> {{ . }} </h1>
+        {{- end }}
+        <h1> What is lazylit? </h1>
+        <p>
+            Lazylit is a collection of heavily documented source code files. Each page of documentation hosted here is written for a specific revision/commit of a source code file that lives somewhere else (i.e. a repository in GitHub).
+        </p>
+        <p>
+            Follow the links<sup>*</sup> below to view available documentation:
+        </p>
+        <form class="filter" id="artifact-filter" role="search" onsubmit="return false" hidden>
+            <input type="search" id="artifact-text" placeholder="Filter artifacts" autocomplete="off">
+            {{- with .Tags }}
+            <select id="artifact-tag">
+                <option value="">All tags</option>
+                {{- range . }}
+                <option>{{ . }}</option>
+                {{- end }}
+            </select>
+            {{- end }}
+        </form>
+        <table id="artifacts" class="artifacts">
+            <thead>
+                <tr>
+                    <th aria-sort="ascending"><button type="button">Artifact</button></th>
+                    <th class="optional"><button type="button">Source file</button></th>
+                    <th class="number" data-type="number"><button type="button">Revisions</button></th>
+                    <th><button type="button">Updated</button></th>
+                    <th class="optional"><button type="button">Authors</button></th>
+                </tr>
+            </thead>
+            <tbody>
+                {{- range .Artifacts }}
+                <tr>
+                    <td data-key="{{ .Name }}">
+                        <a href="{{ .Name }}/index.html">{{ .Name }}</a>
+                        {{- with .Summary }}
+                        <div class="summary">{{ . }}</div>
+                        {{- end }}
+                        {{- with .Tags }}
+                        <div class="tags">{{ range $i, $tag := . }}{{ if $i }} {{ end }}<span class="tag">{{ $tag }}</span>{{ end }}</div>
+                        {{- end }}
+                    </td>
+                    <td class="optional">{{ .Latest.SourceFileName }}</td>
+                    <td class="number">{{ .Revisions }}</td>
+                    <td data-key="{{ .Latest.CommitDate.Format "2006-01-02" }}">{{ .Latest.CommitDateString }}</td>
+                    <td class="optional">{{ range $i, $author := .Authors }}{{ if $i }}, {{ end }}{{ $author }}{{ end }}</td>
+                </tr>
+                {{- end }}
+            </tbody>
+        </table>
+        <p id="artifact-none" hidden> No artifacts match. </p>
+        <p class="footnote">
+            <sup>*</sup> They link to "redirection pages" which provides a consistent identifier and landing page even if the source code file changes names over time.
+        </p>
+        <hr>
+        <h2>Motivation</h2>
+        <p>
+            I wanted a simple way to share extensive code comments without the ongoing maintenance burden and inevitable code/comment drift that is characteristic of traditional code commenting practice. 
+        </p>
+        <p>
+            I've often heard advice that usually sounds like "don't use advanced features of a language/tool, because it makes code harder to understand".
+            With a tool like GNU <code>make</code>, a lot of these "advanced" features can replace dozens of lines of bespoke shell scripts.
+            Following this advice then turns an opportunity to learn a common tool <i>well</i> into a grueling slog through a coworker's (or your own) buggy mess.
+            Instead of avoiding certain features and tools, I believe that a well-written set of notes (with links to relevant documentation) can go a long way in improving code understandability.
+            There are thousands of programming tools and languages; most people only know a few.
+            For <code>make</code> in particular, my (limited) experience has shown me that programmers without experience in C are likely to be unfamiliar with <code>make</code> in general.
+            Expecting that all code should be immediately understandable to anyone at first glance is unrealistic.
+            But we can certainly leave clues behind us that point readers <i>toward</i> understanding.
+        </p>
+        <p>
+            But fancy tool/language features are not the only thing worth documenting in this way.
+            <i>Project</i>- and <i>team</i>-specific conventions and patterns are worth documenting as well.
+            For example, if all your team's projects have a Makefile with a similar structure, an explanation of that structure and pattern could accelerate a new developer's ability to read and understand the team's projects.
+        </p>
+        <p>
+            Approaching existing code bases is one of the hardest things I've had to do as a programmer. This project as an attempt to address that challenge. I hope it will make the lives of my coworkers easier by making it easier for new developers to learn about the conventions and tricks used in codebases I work on.
+        </p>
+        <h2> Why not store the explanation in the repo, next to the source itself? </h2>
+        <p>
+            Storing an explanation next to the code (either in comments, as separate files, or using a proper <a href="https://en.wikipedia.org/wiki/Literate_programming">literate programming</a> tool) comes with the expectation that it is always kept up-to-date with the source.
+            This incurs a maintenance burden, which I felt would be too cumbersome and in fact, unnecessary.
+            The focus should be on explaining the <i>patterns</i> and the <i>features</i> or <i>paradigms</i> being used; the things that will help a developer read the code itself.
+            Thus, an explanation written against a version of the code slightly out of date should still be useful, because they can apply what they learn to the newer code.
+        </p>
+        <h2>Acknowledgements</h2>
+        <p>
+            I borrowed heavily from the <a href="http://ashkenas.com/docco/">Docco</a> project and its derivatives. I am especially grateful for Nikhil Marathe's golang port of Docco, <a href="https://github.com/nikhilm/gocco">gocco</a>, from which I borrowed (i.e. copied) a large portion of this code.
+        </p>
+        <hr>
+        <footer>
+            <p>Lazylit was created by Daniel Sabsay and is under the MIT License.</p>
+        </footer>
+    </div>
+  </div>
+  {{- template "footer.html" . }}
+  <script src="{{ .Root }}search.js"></script>
+  <script src="{{ .Root }}artifacts.js"></script>
+</body>
+</html>
+`
+
+var INDEX_HTML = `
+<!DOCTYPE html>
+
+<html>
+<head>
+    <title>{{ .Name }}{{ with .Config.Title }} | {{ . }}{{ end }}</title>
+  <meta http-equiv="content-type" content="text/html; charset=UTF-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">{{ with .Config.BaseURL }}
+  <link rel="canonical" href="{{ . }}/{{ $.Name }}/" />{{ end }}
+  <link rel="stylesheet" media="all" href="{{ .Root }}gocco.css" />{{ if .Config.DarkStyle }}
+  <script src="{{ .Root }}theme.js"></script>{{ end }}
+</head>
+
+<body>
+  {{- template "header.html" . }}
+  <div id="container">
+    <div id="background"></div>
+    <div id="content">
+        <form class="search" role="search" onsubmit="return false">
+            <input type="search" id="search" placeholder="Search notes and code" autocomplete="off" data-root="{{ .Root }}">
+            <ol id="search-results"></ol>
+        </form>
+        <h1> {{ .Name }} </h1>
+        <p> Notes are available for these revisions (commits): </p>
+        <ul>
+            {{ range .Snapshots }}
+            <li>
+                <a href="{{ .Destination | base }}">
+                {{ .CommitDateString }} ({{ .SourceFileName }})
+                </a>
+            </li>
+            {{ end }}
+        </ul>
+        {{- with .Diffs }}
+        <p> See what changed between revisions: </p>
+        <ul>
+            {{ range . }}
+            <li>
+                <a href="{{ .Destination | base }}">
+                {{ .Older.CommitDateString }} &rarr; {{ .Newer.CommitDateString }}
+                </a>
+            </li>
+            {{ end }}
+        </ul>
+        {{- end }}
+    </div>
+  </div>
+  {{- template "footer.html" . }}
+  <script src="{{ .Root }}search.js"></script>
+</body>
+</html>
+`
+
+var HTML = `
+<!DOCTYPE html>
+
+<html>
+<head>
+    <title>{{ .Title }}{{ with .Config.Title }} | {{ . }}{{ end }}</title>
+  <meta http-equiv="content-type" content="text/html; charset=UTF-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">{{ with .Config.BaseURL }}
+  <link rel="canonical" href="{{ . }}/{{ $.Snapshot.Destination }}" />{{ end }}
+  <link rel="stylesheet" media="all" href="{{ .Root }}gocco.css" />{{ if .Config.DarkStyle }}
+  <script src="{{ .Root }}theme.js"></script>{{ end }}
+</head>
+<body>
+  {{- template "header.html" . }}
+  <div id="container">
+    <div id="background"></div>
+    {{ template "revisions.html" . }}
+    <table cellpadding="0" cellspacing="0">
+      <thead>
+        <tr>
+          <th class="docs">
+            <form class="search" role="search" onsubmit="return false">
+                <input type="search" id="search" placeholder="Search notes and code" autocomplete="off" data-root="{{ .Root }}">
+                <ol id="search-results"></ol>
+            </form>
+            <h1>
+                {{ .Title }}
+            </h1>
+            <p> <i>
+                Viewing notes written by {{ .Snapshot.DocAuthor }} for {{ .Snapshot.SourceFileName }} at revision <a href="{{ .Snapshot.SourceLink }}">{{ .Snapshot.Commit }} ({{ .Snapshot.CommitDateString }})</a>. Select other revisions via the menu to the right.
+            </i>{{ with .Staleness }} <span class="staleness{{ if .Current }} current{{ end }}">{{ .Summary }}</span>{{ end }} </p>
+          </th>
+          <th class="code">
+          </th>
+        </tr>
+      </thead>
+      <tbody>
+          {{ range .Sections }}
+          <tr id="section-{{ .Index }}">
+            <td class="docs">
+              <div class="pilwrap">
+                  <a class="pilcrow" href="#section-{{ .Index }}">&#182;</a>
+              </div>
+                {{ .DocsHTML }}
+            </td>
+            <td class="code">
+                <div class="highlight"><pre>
+                {{- range .Lines -}}
+                {{ if .Number }}<a class="lineno" id="L{{ .Number }}" href="{{ $.Snapshot.SourceLink }}#L{{ .Number }}">{{ .Number }}</a>{{ else if $.Numbered }}<span class="lineno"></span>{{ end }}{{ .HTML }}
+{{ end -}}
+                </pre></div>
+            </td>
+          </tr>
+          {{ end }}
+      </tbody>
+    </table>
+  </div>
+  {{- template "footer.html" . }}
+  <script src="{{ .Root }}search.js"></script>
+</body>
+</html>
+`
+
+var DIFF_HTML = `
+<!DOCTYPE html>
+
+<html>
+<head>
+    <title>{{ .Title }}: {{ .Diff.Older.CommitDateString }} to {{ .Diff.Newer.CommitDateString }}{{ with .Config.Title }} | {{ . }}{{ end }}</title>
+  <meta http-equiv="content-type" content="text/html; charset=UTF-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">{{ with .Config.BaseURL }}
+  <link rel="canonical" href="{{ . }}/{{ $.Diff.Destination }}" />{{ end }}
+  <link rel="stylesheet" media="all" href="{{ .Root }}gocco.css" />{{ if .Config.DarkStyle }}
+  <script src="{{ .Root }}theme.js"></script>{{ end }}
+</head>
+<body>
+  {{- template "header.html" . }}
+  <div id="container">
+    <div id="diff">
+      <h1> {{ .Title }} </h1>
+      <p> <i>
+          Changes between <a href="{{ .Diff.Older.PageName }}.html">{{ .Diff.Older.Commit }} ({{ .Diff.Older.CommitDateString }})</a>
+          and <a href="{{ .Diff.Newer.PageName }}.html">{{ .Diff.Newer.Commit }} ({{ .Diff.Newer.CommitDateString }})</a>.
+          See <a href="index.html">all revisions</a>.
+      </i> </p>
+      <h2> Notes </h2>
+      {{- range .Notes }}
+      <div class="note {{ .Kind }}">
+        <div class="kind">{{ .Kind }}</div>
+        {{- with .OldHTML }}
+        <div class="old">{{ . }}</div>
+        {{- end }}
+        {{- with .NewHTML }}
+        <div class="new">{{ . }}</div>
+        {{- end }}
+      </div>
+      {{- else }}
+      <p> The notes did not change. </p>
+      {{- end }}
+      <h2> Code </h2>
+      {{- if .Rows }}
+      <table class="code-diff" cellpadding="0" cellspacing="0">
+        <thead>
+          <tr><th>{{ .Diff.Older.CommitDateString }}</th><th>{{ .Diff.Newer.CommitDateString }}</th></tr>
+        </thead>
+        <tbody>
+          {{- range .Rows }}
+          {{- if eq .Kind "skip" }}
+          <tr class="skip"><td colspan="2">&hellip;</td></tr>
+          {{- else }}
+          <tr class="{{ .Kind }}"><td class="old"><pre>{{ .OldHTML }}</pre></td><td class="new"><pre>{{ .NewHTML }}</pre></td></tr>
+          {{- end }}
+          {{- end }}
+        </tbody>
+      </table>
+      {{- else }}
+      <p> The code did not change. </p>
+      {{- end }}
+    </div>
+  </div>
+  {{- template "footer.html" . }}
+</body>
+</html>
+`
+
+// the search box script, written to the site's root. Each page says where the
+// root is in the box's `data-root` attribute.
+var SEARCH_JS = `// lazylit search: looks through search-index.js as you type
+(function () {
+  var input = document.getElementById("search");
+  var results = document.getElementById("search-results");
+  if (!input || !results) {
+    return;
+  }
+  var root = input.getAttribute("data-root") || "";
+  var index = null;
+  var requested = false;
+
+  function load() {
+    if (requested) {
+      return;
+    }
+    requested = true;
+    // a script, unlike fetched JSON, loads from file:// URLs too
+    var script = document.createElement("script");
+    script.src = root + "search-index.js";
+    script.onload = function () {
+      index = window.lazylitSearchIndex || [];
+      search();
+    };
+    script.onerror = function () {
+      results.innerHTML = "<li>The search index can't be loaded.</li>";
+    };
+    document.head.appendChild(script);
+  }
+
+  function escape(s) {
+    return s.replace(/[&<>"]/g, function (c) {
+      return { "&": "&amp;", "<": "&lt;", ">": "&gt;", "\"": "&quot;" }[c];
+    });
+  }
+
+  // the part of the text around the first occurrence of term
+  function snippet(entry, term) {
+    var text = entry.text;
+    if (!text) {
+      return (entry.identifiers || []).slice(0, 8).join(", ");
+    }
+    var start = Math.max(0, text.toLowerCase().indexOf(term) - 40);
+    return (start > 0 ? "\u2026" : "") + text.slice(start, start + 140) + (start + 140 < text.length ? "\u2026" : "");
+  }
+
+  // every term must match; a matching identifier counts more than text
+  function score(entry, terms) {
+    var text = entry.text.toLowerCase();
+    var ids = (entry.identifiers || []).map(function (id) { return id.toLowerCase(); });
+    var total = 0;
+    for (var i = 0; i < terms.length; i++) {
+      var term = terms[i];
+      if (ids.indexOf(term) >= 0) {
+        total += 3;
+      } else if (ids.some(function (id) { return id.indexOf(term) >= 0; })) {
+        total += 2;
+      } else if (text.indexOf(term) >= 0) {
+        total += 1;
+      } else {
+        return 0;
+      }
+    }
+    return total;
+  }
+
+  function search() {
+    var terms = input.value.toLowerCase().split(/\s+/).filter(Boolean);
+    results.innerHTML = "";
+    if (terms.length === 0 || index === null) {
+      return;
+    }
+    var hits = [];
+    index.forEach(function (entry) {
+      var s = score(entry, terms);
+      if (s > 0) {
+        hits.push({ entry: entry, score: s });
+      }
+    });
+    hits.sort(function (a, b) { return b.score - a.score; });
+    if (hits.length === 0) {
+      results.innerHTML = "<li>No matches</li>";
+      return;
+    }
+    hits.slice(0, 20).forEach(function (hit) {
+      var e = hit.entry;
+      var li = document.createElement("li");
+      li.innerHTML = "<a href=\"" + escape(root + e.url) + "\">" + escape(e.artifact) +
+        " <span class=\"date\">" + escape(e.date) + "</span></a><p>" + escape(snippet(e, terms[0])) + "</p>";
+      results.appendChild(li);
+    });
+  }
+
+  input.addEventListener("focus", load);
+  input.addEventListener("input", function () { load(); search(); });
+  input.addEventListener("keydown", function (event) {
+    if (event.key === "Escape") {
+      input.value = "";
+      search();
+    }
+  });
+})();
+`
+
+var THEME_JS = `// lazylit theme: applies the reader's choice of light or dark pages, and
+// lets the button in the corner of every page change it
+(function () {
+  var key = "lazylit-theme";
+  var root = document.documentElement;
+  try {
+    var saved = localStorage.getItem(key);
+    if (saved) {
+      root.setAttribute("data-theme", saved);
+    }
+  } catch (e) {
+    // storage can be disabled; the system setting still applies
+  }
+
+  function current() {
+    var theme = root.getAttribute("data-theme");
+    if (theme) {
+      return theme;
+    }
+    return window.matchMedia && window.matchMedia("(prefers-color-scheme: dark)").matches ? "dark" : "light";
+  }
+
+  document.addEventListener("DOMContentLoaded", function () {
+    var button = document.getElementById("theme_toggle");
+    if (!button) {
+      return;
+    }
+    function label() {
+      button.textContent = current() == "dark" ? "Light pages" : "Dark pages";
+    }
+    label();
+    button.addEventListener("click", function () {
+      var theme = current() == "dark" ? "light" : "dark";
+      root.setAttribute("data-theme", theme);
+      try {
+        localStorage.setItem(key, theme);
+      } catch (e) {
+      }
+      label();
+    });
+  });
+})();
+`
+
+// the script sorting and filtering the table of artifacts on the front page,
+// written to the site's root. Without it the table is shown as built, sorted
+// by name.
+var ARTIFACTS_JS = `// lazylit artifacts: sorts and filters the front page's table of artifacts
+(function () {
+  var table = document.getElementById("artifacts");
+  var form = document.getElementById("artifact-filter");
+  if (!table || !form) {
+    return;
+  }
+  var text = document.getElementById("artifact-text");
+  var tag = document.getElementById("artifact-tag");
+  var none = document.getElementById("artifact-none");
+  var body = table.tBodies[0];
+  var rows = Array.prototype.slice.call(body.rows);
+  form.hidden = false;
+
+  function tags(row) {
+    return Array.prototype.map.call(row.querySelectorAll(".tag"), function (t) { return t.textContent; });
+  }
+
+  // show the rows containing every word typed, with the chosen tag
+  function filter() {
+    var terms = text.value.toLowerCase().split(/\s+/).filter(Boolean);
+    var shown = 0;
+    rows.forEach(function (row) {
+      var content = row.textContent.toLowerCase();
+      var match = terms.every(function (term) { return content.indexOf(term) >= 0; }) &&
+        (!tag || tag.value === "" || tags(row).indexOf(tag.value) >= 0);
+      row.hidden = !match;
+      if (match) {
+        shown++;
+      }
+    });
+    none.hidden = shown > 0;
+  }
+
+  function key(row, column) {
+    var cell = row.cells[column];
+    return cell.getAttribute("data-key") || cell.textContent.trim().toLowerCase();
+  }
+
+  // sort by the column of the header th, the other way round if it already is
+  function sort(th) {
+    var column = th.cellIndex;
+    var numeric = th.getAttribute("data-type") === "number";
+    var ascending = th.getAttribute("aria-sort") !== "ascending";
+    Array.prototype.forEach.call(th.parentNode.cells, function (cell) { cell.removeAttribute("aria-sort"); });
+    th.setAttribute("aria-sort", ascending ? "ascending" : "descending");
+    rows.sort(function (a, b) {
+      var x = key(a, column), y = key(b, column);
+      var order = numeric ? x - y : x < y ? -1 : x > y ? 1 : 0;
+      return ascending ? order : -order;
+    });
+    rows.forEach(function (row) { body.appendChild(row); });
+  }
+
+  table.tHead.addEventListener("click", function (event) {
+    var button = event.target.closest("button");
+    if (button) {
+      sort(button.parentNode);
+    }
+  });
+  body.addEventListener("click", function (event) {
+    if (tag && event.target.classList.contains("tag")) {
+      tag.value = event.target.textContent;
+      filter();
+    }
+  });
+  text.addEventListener("input", filter);
+  if (tag) {
+    tag.addEventListener("change", filter);
+  }
+  filter();
+})();
+`
@@ -0,0 +1,147 @@
+package site
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/alecthomas/chroma"
+	"github.com/alecthomas/chroma/lexers"
+)
+
+// ## Search
+// The site is static, so search happens in the browser: every build writes
+// each section's notes, as plain text, and the identifiers in its code to
+// `search-index.js`, and `search.js` looks through them as you type. The
+// index is small enough to load whole; hits link to the section's anchor. It
+// is a script rather than JSON so that browsers load it from `file://` URLs
+// too, for sites read straight from disk.
+//
+// Only the sections of pages rebuilt by this build are read again; the rest
+// are taken from the index the last build wrote.
+
+// where the index and the script go, within the output directory
+const (
+	searchIndexName  = "search-index.js"
+	searchScriptName = "search.js"
+)
+
+// what the index script wraps the JSON entries in
+const (
+	searchIndexStart = "window.lazylitSearchIndex = "
+	searchIndexEnd   = ";\n"
+)
+
+// a `searchEntry` is a section of a snapshot, as the search box sees it
+type searchEntry struct {
+	Artifact string `json:"artifact"`
+	// the snapshot's commit date
+	Date string `json:"date"`
+	// relative to the site root, e.g.
+	// `tiddlylisp/tiddlylisp.may_16_2020.html#section-3`
+	URL         string   `json:"url"`
+	Section     int      `json:"section"`
+	Text        string   `json:"text"`
+	Identifiers []string `json:"identifiers,omitempty"`
+}
+
+// matches HTML tags, to turn rendered notes into plain text
+var tagMatcher = regexp.MustCompile(`<[^>]*>`)
+
+// matches the MathML of a formula up to its TeX, which is what gets indexed
+var mathMatcher = regexp.MustCompile(`(?s)<math[^>]*>.*?<annotation[^>]*>`)
+
+// write the search index of every snapshot of `artifacts`, reusing the
+// entries of pages `prev` and `next` agree are up to date. Snapshots that
+// can't be read were reported when their pages were generated.
+func (b *builder) generateSearchIndex(ctx context.Context, artifacts []Artifact, prev, next *Manifest) error {
+	cached := b.previousSearchIndex()
+	entries := []searchEntry{}
+	for _, artifact := range artifacts {
+		for _, a := range artifact.Snapshots {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if hash := next.hash(a.Destination()); cached != nil && hash != "" && hash == prev.hash(a.Destination()) {
+				entries = append(entries, cached[a.Destination()]...)
+				continue
+			}
+			_, sections, err := b.load(a)
+			if err != nil {
+				continue
+			}
+			for i, sec := range sectionSlice(sections) {
+				text := plainText(renderMarkdown(b.textMD, a.DocFileName, sec.docsText, nil))
+				ids := identifiers(a.language, sec.codeText)
+				if text == "" && len(ids) == 0 {
+					continue
+				}
+				entries = append(entries, searchEntry{
+					Artifact:    artifact.Name,
+					Date:        a.CommitDateString,
+					URL:         fmt.Sprintf("%v#section-%d", a.Destination(), i+1),
+					Section:     i + 1,
+					Text:        text,
+					Identifiers: ids,
+				})
+			}
+		}
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	next.record(searchIndexName, "")
+	return writeIfChanged(b.outPath(searchIndexName), []byte(searchIndexStart+string(data)+searchIndexEnd))
+}
+
+// the entries of the index the last build wrote, by page, or nil if there is
+// none
+func (b *builder) previousSearchIndex() map[string][]searchEntry {
+	data, err := ioutil.ReadFile(b.outPath(searchIndexName))
+	if err != nil || !bytes.HasPrefix(data, []byte(searchIndexStart)) {
+		return nil
+	}
+	var entries []searchEntry
+	data = bytes.TrimSuffix(bytes.TrimPrefix(data, []byte(searchIndexStart)), []byte(searchIndexEnd))
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil
+	}
+	pages := make(map[string][]searchEntry)
+	for _, e := range entries {
+		page := strings.SplitN(e.URL, "#", 2)[0]
+		pages[page] = append(pages[page], e)
+	}
+	return pages
+}
+
+// the text of rendered HTML, with the whitespace collapsed
+func plainText(htmlText []byte) string {
+	text := mathMatcher.ReplaceAllString(string(htmlText), "")
+	text = html.UnescapeString(tagMatcher.ReplaceAllString(text, ""))
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// the distinct names in `code`, in order of appearance
+func identifiers(language *Language, code []byte) []string {
+	iterator, err := chroma.Coalesce(lexers.Get(language.name)).Tokenise(nil, string(code))
+	if err != nil {
+		return nil
+	}
+	var ids []string
+	seen := make(map[string]bool)
+	for _, t := range iterator.Tokens() {
+		name := strings.TrimSpace(t.Value)
+		if !t.Type.InCategory(chroma.Name) || len(name) < 2 || seen[name] {
+			continue
+		}
+		seen[name] = true
+		ids = append(ids, name)
+	}
+	return ids
+}
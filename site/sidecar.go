@@ -0,0 +1,194 @@
+package site
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma/lexers"
+)
+
+// ## Sidecar notes
+// Interleaving notes with the code doesn't work for formats without comments,
+// like JSON or CSV, and it changes the file being documented. A sidecar
+// artifact keeps the source file untouched, next to a Markdown file named
+// after it, e.g. `package.may_16_2020.json` and `package.may_16_2020.json.md`.
+// The notes file starts with the usual headers as front matter, and each note
+// is a `## lines 40-62` (or `## line 7`) heading followed by Markdown about
+// those lines of the source:
+//
+//     ---
+//     Commit: 1f1a39a36e8a2b9f3d7e1c2a4b5c6d7e8f9a0b1c
+//     CommitDate: May 16 2020
+//     SourceFile: package.json
+//     SourceLink: https://github.com/owner/repo/blob/1f1a39a/package.json
+//     DocAuthor: Jane Doe
+//     ---
+//
+//     What the file is for, shown next to the lines before the first note.
+//
+//     ## lines 3-10
+//     What these lines do.
+//
+// Lines no note covers are shown too, without notes. The source's language
+// only matters for highlighting, so any chroma lexer will do.
+
+// the extension of sidecar notes files, added to the name of the source file
+const SidecarExt = ".md"
+
+// the line opening and closing the front matter
+const frontMatterDelimiter = "---"
+
+// matches a header in the front matter, e.g. `Commit: 1f1a39a`
+var frontMatterHeader = regexp.MustCompile(`^\s*(\w+):\s*(.*?)\s*$`)
+
+// matches the heading of a note, e.g. `## lines 40-62`
+var lineRangeMatcher = regexp.MustCompile(`(?i)^##\s+lines?\s+(\d+)(?:\s*-\s*(\d+))?\s*$`)
+
+// read the sidecar artifact made of the notes file `notesPath` and the source
+// file `p`, both paths within the artifacts tree
+func (b *builder) parseSidecar(name, notesPath, p string) (*Snapshot, error) {
+	file := b.fileName(notesPath)
+	notes, err := fs.ReadFile(b.src, notesPath)
+	if err != nil {
+		return nil, err
+	}
+	code, err := fs.ReadFile(b.src, p)
+	if err != nil {
+		return nil, err
+	}
+	lines := bytes.Split(notes, []byte("\n"))
+	if string(bytes.TrimSpace(lines[0])) != frontMatterDelimiter {
+		return nil, &Diagnostic{File: file, Line: 1, Check: "front-matter", Msg: "sidecar notes must start with the headers between --- lines"}
+	}
+
+	a := Snapshot{ArtifactName: name, DocFileName: file, path: p, notesPath: notesPath}
+	problems := b.readHeaders(&a, lines, 1, frontMatterHeader)
+	if a.FirstNonHeaderLine == len(lines) || string(bytes.TrimSpace(lines[a.FirstNonHeaderLine])) != frontMatterDelimiter {
+		problems = append(problems, &Diagnostic{file, a.FirstNonHeaderLine + 1, "front-matter", "expected --- to end the headers"})
+	} else {
+		a.FirstNonHeaderLine++
+	}
+
+	lexer := ""
+	for _, line := range lines[1:a.FirstNonHeaderLine] {
+		if m := frontMatterHeader.FindStringSubmatch(string(line)); m != nil && m[1] == "Language" {
+			lexer = m[2]
+		}
+	}
+	if a.language, err = b.langs.detectLexer(p, code, lexer); err != nil {
+		problems = append(problems, &Diagnostic{File: file, Check: "language", Msg: err.Error()})
+	}
+	_, rangeProblems := splitSidecar(file, lines, a.FirstNonHeaderLine, code)
+	problems = append(problems, rangeProblems...)
+
+	if len(problems) > 0 {
+		return nil, problems
+	}
+	return &a, nil
+}
+
+// the language to highlight the sidecar source `file` with, named by
+// `lexer` or guessed like any other artifact's. Failing that, any lexer
+// chroma thinks fits, or plain text.
+func (ls *Languages) detectLexer(file string, data []byte, lexer string) (*Language, error) {
+	if lexer != "" {
+		l := lexers.Get(lexer)
+		if l == nil {
+			return nil, fmt.Errorf("No chroma lexer named %q", lexer)
+		}
+		return &Language{name: l.Config().Name}, nil
+	}
+	if lang, err := ls.Detect(file, data); err == nil {
+		return &Language{name: lang.name}, nil
+	}
+	l := lexers.Match(stripDateSuffix(path.Base(file)))
+	if l == nil {
+		l = lexers.Analyse(string(data))
+	}
+	if l == nil {
+		l = lexers.Get("plaintext")
+	}
+	return &Language{name: l.Config().Name}, nil
+}
+
+// split the notes in `lines`, starting at line `first`, and the source `code`
+// into `Section`s. Notes with bad line ranges are left out and reported as
+// problems in `file`.
+func splitSidecar(file string, lines [][]byte, first int, code []byte) (*list.List, DiagnosticList) {
+	var codeLines []string
+	if len(code) > 0 {
+		codeLines = strings.Split(strings.TrimSuffix(string(code), "\n"), "\n")
+	}
+
+	var problems DiagnosticList
+	sections := new(list.List)
+	save := func(docs []byte, docsLine, from, to int) {
+		if len(bytes.TrimSpace(docs)) == 0 && from >= to {
+			return
+		}
+		codeText := new(bytes.Buffer)
+		var sourceLines []int
+		for i, line := range codeLines[from:to] {
+			codeText.WriteString(line)
+			codeText.WriteString("\n")
+			sourceLines = append(sourceLines, from+i+1)
+		}
+		sections.PushBack(&Section{docs, codeText.Bytes(), nil, nil, nil, docsLine, from, sourceLines})
+	}
+
+	var headings []int
+	for i := first; i < len(lines); i++ {
+		if lineRangeMatcher.Match(lines[i]) {
+			headings = append(headings, i)
+		}
+	}
+	// lines `i` up to `end` as one text
+	text := func(i, end int) []byte {
+		return bytes.Join(lines[i:end], []byte("\n"))
+	}
+
+	// the text before the first note goes with the lines before it
+	introEnd := len(lines)
+	if len(headings) > 0 {
+		introEnd = headings[0]
+	}
+	pending := text(first, introEnd)
+	next := 0
+	for k, h := range headings {
+		end := len(lines)
+		if k+1 < len(headings) {
+			end = headings[k+1]
+		}
+		m := lineRangeMatcher.FindStringSubmatch(string(lines[h]))
+		from, _ := strconv.Atoi(m[1])
+		to := from
+		if m[2] != "" {
+			to, _ = strconv.Atoi(m[2])
+		}
+		problem := ""
+		switch {
+		case from < 1 || to < from:
+			problem = fmt.Sprintf("%v-%v is not a range of lines", from, to)
+		case to > len(codeLines):
+			problem = fmt.Sprintf("line %v is past the end of the source, which has %v lines", to, len(codeLines))
+		case from <= next:
+			problem = fmt.Sprintf("line %v is already covered by the previous note; notes must be in order and not overlap", from)
+		}
+		if problem != "" {
+			problems = append(problems, &Diagnostic{file, h + 1, "line-range", problem})
+			continue
+		}
+
+		save(pending, first, next, from-1)
+		save(text(h+1, end), h+1, from-1, to)
+		pending, next = nil, to
+	}
+	save(pending, first, next, len(codeLines))
+	return sections, problems
+}
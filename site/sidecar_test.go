@@ -0,0 +1,69 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSplitSidecar(t *testing.T) {
+	code := "one\ntwo\nthree\nfour\nfive\n"
+	tests := []struct {
+		notes string
+		// the sections, as `docs|code` pairs
+		want []string
+		// the line and message of each problem
+		problems []string
+		// the upstream line number of each code line, by section
+		lines string
+	}{
+		{"intro", []string{
+			`"intro"|"one\ntwo\nthree\nfour\nfive\n"`,
+		}, nil, "[1 2 3 4 5]"},
+		{"intro\n## lines 2-3\nmiddle", []string{
+			`"intro"|"one\n"`,
+			`"middle"|"two\nthree\n"`,
+			`""|"four\nfive\n"`,
+		}, nil, "[1] [2 3] [4 5]"},
+		{"## line 1\nfirst\n## Lines 5 - 5\nlast", []string{
+			`"first"|"one\n"`,
+			`""|"two\nthree\nfour\n"`,
+			`"last"|"five\n"`,
+		}, nil, "[1] [2 3 4] [5]"},
+		// a note with a bad range is left out, the lines it covered shown
+		// with those before them
+		{"## lines 3-2\nbackwards\n## line 9\npast the end\n## line 4\nfour", []string{
+			`""|"one\ntwo\nthree\n"`,
+			`"four"|"four\n"`,
+			`""|"five\n"`,
+		}, []string{
+			"1: 3-2 is not a range of lines",
+			"3: line 9 is past the end of the source, which has 5 lines",
+		}, "[1 2 3] [4] [5]"},
+		{"## lines 1-3\na\n## line 2\nb", []string{
+			`"a"|"one\ntwo\nthree\n"`,
+			`""|"four\nfive\n"`,
+		}, []string{
+			"3: line 2 is already covered by the previous note; notes must be in order and not overlap",
+		}, "[1 2 3] [4 5]"},
+	}
+	for _, tt := range tests {
+		lines := bytes.Split([]byte(tt.notes), []byte("\n"))
+		sections, problems := splitSidecar("a.json.md", lines, 0, []byte(code))
+		got := showSections(sectionSlice(sections))
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%q:\ngot  %q\nwant %q", tt.notes, got, tt.want)
+		}
+		if got := showLineNumbers(sectionSlice(sections)); got != tt.lines {
+			t.Errorf("%q: got line numbers %v, want %v", tt.notes, got, tt.lines)
+		}
+		var gotProblems []string
+		for _, d := range problems {
+			gotProblems = append(gotProblems, fmt.Sprintf("%v: %v", d.Line, d.Msg))
+		}
+		if strings.Join(gotProblems, "\n") != strings.Join(tt.problems, "\n") {
+			t.Errorf("%q: got problems %q, want %q", tt.notes, gotProblems, tt.problems)
+		}
+	}
+}
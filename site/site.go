@@ -0,0 +1,446 @@
+// Package site generates a lazylit site: static HTML pages explaining
+// revisions of source files, from a tree of commented copies of those files.
+//
+// The input tree holds one directory per artifact, each with one file per
+// revision:
+//
+//	crazy_makefile/
+//	    Makefile.jul_18_2020
+//	    Makefile.apr_1_2020
+//
+// `Build` renders it into an output directory, and `Check` reports the
+// problems a build would find without writing anything.
+package site
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark"
+)
+
+// the lazylit version; pages built by another version are regenerated
+const Version = "0.2.2"
+
+// ## Types
+
+// `Options` say where a site comes from, where it goes and how it is built.
+// The zero value builds `artifacts/` into `docs/` with the default
+// configuration.
+type Options struct {
+	// The artifacts tree. If nil, `SrcDir` is read from disk.
+	Src fs.FS
+	// The artifacts directory, `Config.Src` by default. With `Src` set it is
+	// only used to name files in diagnostics.
+	SrcDir string
+	// Where the HTML is written, `Config.Out` by default
+	OutDir string
+	// The configuration, usually read with `LoadConfig`; nil means the
+	// defaults
+	Config *Config
+	// Regenerate every page, even if its inputs haven't changed
+	Force bool
+	// Receives a line for every page written; nil discards them
+	Log *log.Logger
+}
+
+// a `Site` is the result of a build or a check
+type Site struct {
+	// Every artifact that has at least one usable snapshot, by name
+	Artifacts []Artifact
+	// Problems with individual files. The affected snapshots were skipped.
+	Problems DiagnosticList
+
+	b *builder
+}
+
+// an `Artifact` is a documented file, with a `Snapshot` per revision
+type Artifact struct {
+	Name string
+	// newest first
+	Snapshots []Snapshot
+}
+
+// a `Snapshot` is one commented revision of an artifact
+type Snapshot struct {
+	ArtifactName       string
+	Commit             string
+	CommitDate         time.Time
+	CommitDateString   string
+	SourceFileName     string
+	SourceLink         string
+	DocFileName        string   // name of the file, including the artifacts directory
+	DocAuthor          string   // author of documentation
+	FirstNonHeaderLine int      // line number of first non-header line
+	Notes              string   // which comments are notes: "all" or "marked"
+	Summary            string   // one line about the artifact, from the optional `Summary` header
+	Tags               []string // from the optional, comma-separated `Tags` header
+	path               string   // slash-separated path within `Options.Src`
+	notesPath          string   // the same for sidecar notes; empty for inline notes
+	language           *Language
+}
+
+type byCommitDate []Snapshot
+
+func (s byCommitDate) Len() int {
+	return len(s)
+}
+
+func (s byCommitDate) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s byCommitDate) Less(i, j int) bool {
+	return s[i].CommitDate.Before(s[j].CommitDate)
+}
+
+// the slash-separated path of the snapshot's page within the output
+// directory
+func (a Snapshot) Destination() string {
+	baseName := path.Base(filepath.ToSlash(a.DocFileName))
+	if a.notesPath != "" {
+		baseName = strings.TrimSuffix(baseName, SidecarExt)
+	}
+	ext := path.Ext(baseName)
+	// keep the date of names like `Makefile.jul_18_2020`
+	if dateSuffixMatcher.MatchString(ext) {
+		ext = ""
+	}
+	destBase := baseName[:len(baseName)-len(ext)]
+	return path.Join(a.ArtifactName, destBase+".html")
+}
+
+// ## Building
+
+// generate the site described by `opts`. Problems with individual artifacts
+// are returned in `Site.Problems`; an error is returned only if the site
+// can't be built at all. Unless `opts.Force` is set, pages whose inputs
+// haven't changed since the last build are left alone.
+func Build(ctx context.Context, opts Options) (*Site, error) {
+	b, err := newBuilder(opts)
+	if err != nil {
+		return nil, err
+	}
+	artifacts, err := b.scan()
+	if err != nil {
+		return nil, err
+	}
+	if b.config.Upstream != "" {
+		if b.upstream, err = openUpstream(b.config.Upstream); err != nil {
+			return nil, err
+		}
+	}
+
+	prev, next := loadManifest(b.outDir, b.log), newManifest(b.outDir, b.config, b.templates.hash)
+	reuse := prev
+	if opts.Force || !next.compatible(prev) {
+		reuse = emptyManifest(b.outDir)
+	}
+
+	if err := b.generateSite(artifacts, next); err != nil {
+		return nil, err
+	}
+	if err := b.generateArtifacts(ctx, artifacts, reuse, next); err != nil {
+		// a cancelled build didn't visit every page, so it can't tell which
+		// ones are stale
+		return nil, err
+	}
+	// a broken artifact keeps the pages it last had, rather than losing them
+	// for not having been rebuilt
+	next.keep(prev, b.troubled())
+	next.prune(prev, b.log)
+	if err := next.save(); err != nil {
+		return nil, err
+	}
+	return b.site(artifacts), nil
+}
+
+// read the artifacts described by `opts` without writing anything
+func Scan(ctx context.Context, opts Options) (*Site, error) {
+	b, err := newBuilder(opts)
+	if err != nil {
+		return nil, err
+	}
+	artifacts, err := b.scan()
+	if err != nil {
+		return nil, err
+	}
+	return b.site(artifacts), nil
+}
+
+// the state of a single build
+type builder struct {
+	src    fs.FS
+	srcDir string
+	outDir string
+	config *Config
+	langs  *Languages
+	log    *log.Logger
+	report *diagnostics
+	// the clone pages are compared to; nil if there is none
+	upstream *upstream
+	// renders notes; `textMD` leaves diagrams as code, for the search index
+	md, textMD goldmark.Markdown
+	diagrams   *diagrams
+	templates  *templates
+}
+
+func newBuilder(opts Options) (*builder, error) {
+	b := &builder{
+		src:    opts.Src,
+		srcDir: opts.SrcDir,
+		outDir: opts.OutDir,
+		config: opts.Config,
+		log:    opts.Log,
+		report: new(diagnostics),
+	}
+	if b.config == nil {
+		b.config = DefaultConfig()
+	}
+	if b.srcDir == "" {
+		b.srcDir = b.config.Src
+	}
+	if b.srcDir == "" {
+		b.srcDir = "artifacts"
+	}
+	if b.outDir == "" {
+		b.outDir = b.config.Out
+	}
+	if b.outDir == "" {
+		b.outDir = "docs"
+	}
+	if b.src == nil {
+		b.src = os.DirFS(b.srcDir)
+	}
+	if b.log == nil {
+		b.log = log.New(ioutil.Discard, "", 0)
+	}
+	var err error
+	if b.langs, err = NewLanguages(b.config); err != nil {
+		return nil, err
+	}
+	if err := validMarkdownExtensions(b.config.MarkdownExtensions); err != nil {
+		return nil, err
+	}
+	b.diagrams = newDiagrams(b.log)
+	b.md = newMarkdown(b.config.MarkdownExtensions, b.diagrams)
+	b.textMD = newMarkdown(b.config.MarkdownExtensions, nil)
+	if b.templates, err = loadTemplates(b.config.Templates); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// the `Site` for the artifacts `b` has seen
+func (b *builder) site(artifacts []Artifact) *Site {
+	return &Site{Artifacts: artifacts, Problems: b.report.list(), b: b}
+}
+
+// the names of the artifacts with a problem so far
+func (b *builder) troubled() map[string]bool {
+	names := make(map[string]bool)
+	for _, d := range b.report.list() {
+		rel, err := filepath.Rel(b.srcDir, d.File)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		names[strings.SplitN(filepath.ToSlash(rel), "/", 2)[0]] = true
+	}
+	return names
+}
+
+// the name of `p`, a path within the artifacts tree, for messages
+func (b *builder) fileName(p string) string {
+	return filepath.Join(b.srcDir, filepath.FromSlash(p))
+}
+
+// the path of `p`, a slash-separated path within the output directory, on
+// disk
+func (b *builder) outPath(p string) string {
+	return filepath.Join(b.outDir, filepath.FromSlash(p))
+}
+
+// read the headers of every file in the artifacts tree, grouping the
+// snapshots by artifact, newest first. Files that can't be used are
+// reported.
+func (b *builder) scan() ([]Artifact, error) {
+	adirs, err := fs.ReadDir(b.src, ".")
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("No %v/ directory found. Run `lazylit init` to create one.", b.srcDir)
+		}
+		return nil, err
+	}
+
+	var artifacts []Artifact
+	for _, dir := range adirs {
+		name := b.fileName(dir.Name())
+		if strings.HasPrefix(dir.Name(), ".") {
+			continue
+		}
+		if !dir.IsDir() {
+			b.report.add(name, &Diagnostic{File: name, Check: "stray-file", Msg: "not an artifact directory; source files belong in " + b.srcDir + "/<name>/"})
+			continue
+		}
+		files, err := fs.ReadDir(b.src, dir.Name())
+		if err != nil {
+			b.report.add(name, err)
+			continue
+		}
+		a := Artifact{Name: dir.Name()}
+		names := make(map[string]bool)
+		for _, file := range files {
+			names[file.Name()] = true
+		}
+		for _, file := range files {
+			fpath := path.Join(dir.Name(), file.Name())
+			if strings.HasPrefix(file.Name(), ".") {
+				continue
+			}
+			if file.IsDir() {
+				fname := b.fileName(fpath)
+				b.report.add(fname, &Diagnostic{File: fname, Check: "stray-file", Msg: "directories inside an artifact are not supported"})
+				continue
+			}
+			var snap *Snapshot
+			switch {
+			case names[file.Name()+SidecarExt]:
+				// the source of a sidecar artifact, read with its notes
+				continue
+			case strings.HasSuffix(file.Name(), SidecarExt) && names[strings.TrimSuffix(file.Name(), SidecarExt)]:
+				snap, err = b.parseSidecar(dir.Name(), fpath, strings.TrimSuffix(fpath, SidecarExt))
+			default:
+				snap, err = b.parseHeaders(dir.Name(), fpath)
+			}
+			if err != nil {
+				b.report.add(b.fileName(fpath), err)
+				continue
+			}
+			a.Snapshots = append(a.Snapshots, *snap)
+		}
+		if len(a.Snapshots) == 0 {
+			continue
+		}
+		sort.Sort(sort.Reverse(byCommitDate(a.Snapshots)))
+		artifacts = append(artifacts, a)
+	}
+	return artifacts, nil
+}
+
+// the headers `parseHeaders` requires, in the order they are usually written
+var HeaderNames = []string{"Commit", "CommitDate", "SourceFile", "SourceLink", "DocAuthor"}
+
+// read the headers of the artifact file `p`, a path within the artifacts
+// tree
+func (b *builder) parseHeaders(name, p string) (*Snapshot, error) {
+	file := b.fileName(p)
+	data, err := fs.ReadFile(b.src, p)
+	if err != nil {
+		return nil, err
+	}
+	language, err := b.langs.Detect(p, data)
+	if err != nil {
+		return nil, &Diagnostic{File: file, Check: "language", Msg: err.Error()}
+	}
+
+	a := Snapshot{ArtifactName: name, DocFileName: file, path: p, language: language}
+	if problems := b.readHeaders(&a, bytes.Split(data, []byte("\n")), 0, language.headerParser); len(problems) > 0 {
+		return nil, problems
+	}
+	return &a, nil
+}
+
+// fill in `a` from the header lines starting at `lines[first]`, parsed by
+// `matcher`, and point `a.FirstNonHeaderLine` just past them. Returns every
+// problem with the headers.
+func (b *builder) readHeaders(a *Snapshot, lines [][]byte, first int, matcher *regexp.Regexp) DiagnosticList {
+	// keep going after a bad header, so every problem is reported at once
+	var problems DiagnosticList
+	problem := func(line int, check, format string, args ...interface{}) {
+		problems = append(problems, &Diagnostic{a.DocFileName, line, check, fmt.Sprintf(format, args...)})
+	}
+
+	a.FirstNonHeaderLine = len(lines)
+	seen := make(map[string]int)
+	for i := first; i < len(lines); i++ {
+		matches := matcher.FindStringSubmatch(string(lines[i]))
+		if matches == nil {
+			a.FirstNonHeaderLine = i
+			break
+		}
+		if prev, ok := seen[matches[1]]; ok {
+			problem(i+1, "duplicate-header", "%v: duplicate header, first given on line %d", matches[1], prev)
+			continue
+		}
+		seen[matches[1]] = i + 1
+		switch matches[1] {
+		case "Commit":
+			a.Commit = matches[2]
+		case "CommitDate":
+			date, err := time.Parse("Jan 2 2006", matches[2])
+			if err != nil {
+				problem(i+1, "commit-date", "CommitDate: cannot parse %q, expected a date like \"Jul 18 2020\"", matches[2])
+			}
+			a.CommitDate = date
+			a.CommitDateString = matches[2]
+		case "SourceFile":
+			a.SourceFileName = matches[2]
+		case "SourceLink":
+			a.SourceLink = matches[2]
+		case "DocAuthor":
+			a.DocAuthor = matches[2]
+		case "Notes":
+			if !validNotesMode(matches[2]) {
+				problem(i+1, "notes-mode", "Notes: must be %q or %q, not %q", notesAll, notesMarked, matches[2])
+			}
+			a.Notes = matches[2]
+		case "Summary":
+			a.Summary = matches[2]
+		case "Tags":
+			for _, tag := range strings.Split(matches[2], ",") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					a.Tags = append(a.Tags, tag)
+				}
+			}
+		}
+	}
+	if a.Notes == "" {
+		a.Notes = b.config.Notes
+	}
+
+	if a.DocAuthor == "" {
+		a.DocAuthor = b.config.DefaultAuthor
+	}
+
+	// check for missing headers; `DocAuthor` may come from the config
+	missingHeaders := make([]string, 0, 5)
+	for _, h := range HeaderNames {
+		if _, ok := seen[h]; !ok && !(h == "DocAuthor" && a.DocAuthor != "") {
+			missingHeaders = append(missingHeaders, h)
+		}
+	}
+	if len(missingHeaders) > 0 {
+		problem(a.FirstNonHeaderLine+1, "missing-header", "missing headers: %v", strings.Join(missingHeaders, ", "))
+	}
+	return problems
+}
+
+// the note marker in effect for `a`, or "" if every comment is a note
+func (b *builder) marker(a Snapshot) string {
+	if a.Notes == notesMarked && a.notesPath == "" {
+		return b.config.NoteMarker
+	}
+	return ""
+}
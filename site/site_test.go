@@ -0,0 +1,57 @@
+package site
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// the headers of a test artifact
+const testHeaders = `// Commit: 1111111
+// CommitDate: Jan 1 2020
+// SourceFile: tool.go
+// SourceLink: https://example.com/tool.go
+// DocAuthor: Jane Doe
+`
+
+func TestBuildKeepsPagesOfBrokenArtifacts(t *testing.T) {
+	out := t.TempDir()
+	src := fstest.MapFS{
+		"tool/tool.jan_1_2020.go":   {Data: []byte(testHeaders + "\n// a note\nfunc a() {}\n")},
+		"other/other.jan_1_2020.go": {Data: []byte(testHeaders + "\n// b note\nfunc b() {}\n")},
+	}
+	build := func() *Site {
+		t.Helper()
+		s, err := Build(context.Background(), Options{Src: src, SrcDir: "artifacts", OutDir: out})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	page := filepath.Join(out, "tool", "tool.jan_1_2020.html")
+
+	if s := build(); len(s.Problems) > 0 {
+		t.Fatalf("problems: %v", s.Problems)
+	}
+	if _, err := os.Stat(page); err != nil {
+		t.Fatal(err)
+	}
+
+	// losing its headers breaks the artifact
+	src["tool/tool.jan_1_2020.go"] = &fstest.MapFile{Data: []byte("// a note\nfunc a() {}\n")}
+	if s := build(); len(s.Problems) == 0 {
+		t.Fatal("no problems reported for an artifact without headers")
+	}
+	if _, err := os.Stat(page); err != nil {
+		t.Errorf("the page of a broken artifact was removed: %v", err)
+	}
+
+	// once the artifact is gone, so is its page
+	delete(src, "tool/tool.jan_1_2020.go")
+	build()
+	if _, err := os.Stat(page); !os.IsNotExist(err) {
+		t.Errorf("the page of a removed artifact is still there: %v", err)
+	}
+}
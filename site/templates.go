@@ -0,0 +1,175 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"text/template"
+)
+
+// ## Templates
+// Pages are rendered by Go templates, which a site can replace with its own:
+// any file in the `templates/` directory next to `lazylit.yaml` (see
+// `templates` in the config) is used instead of the built-in one of the same
+// name. There are four pages,
+//
+// * `page.html`, a snapshot, given a `TemplateData`
+// * `artifact.html`, an artifact's list of revisions, given an
+//   `IndexTemplateData`
+// * `index.html`, the front page, given an `AboutTemplateData`
+// * `diff.html`, the changes between two revisions, given a
+//   `DiffTemplateData`
+//
+// and the partials every page includes with `{{ template "header.html" . }}`:
+// `header.html` and `footer.html`, at the top and bottom of every page, and
+// `revisions.html`, the menu of other revisions on snapshot pages. Any other
+// `.html` file in `templates/` is a partial too, available to all pages. Each
+// page's data embeds a `Page`, so partials can rely on its fields whatever
+// the page. `lazylit templates export` writes the built-ins out for editing.
+
+// the pages, by template name
+const (
+	pageTemplate     = "page.html"
+	artifactTemplate = "artifact.html"
+	indexTemplate    = "index.html"
+	diffTemplate     = "diff.html"
+)
+
+// the built-in templates, by name
+var builtinTemplates = map[string]string{
+	pageTemplate:     HTML,
+	artifactTemplate: INDEX_HTML,
+	indexTemplate:    ABOUT_HTML,
+	diffTemplate:     DIFF_HTML,
+	"header.html":    HEADER_HTML,
+	"footer.html":    FOOTER_HTML,
+	"revisions.html": REVISIONS_HTML,
+}
+
+// a `Page` is what every template is given, besides its own data
+type Page struct {
+	Config *Config
+	// the way from the page to the root of the site, e.g. `../`, for links
+	// to the stylesheet, scripts and other pages
+	Root string
+	// the lazylit version that built the page
+	Version string
+}
+
+// the functions available to templates
+var templateFuncs = template.FuncMap{
+	"base":        path.Base,
+	"destination": Snapshot.Destination,
+}
+
+// a `templates` holds the parsed templates of a build, by page
+type templates struct {
+	pages map[string]*template.Template
+	// of every template's text, so a change to any of them rebuilds the
+	// pages
+	hash string
+}
+
+// parse the templates, those in `dir` taking the place of the built-ins.
+// A missing `dir` means the built-ins alone.
+func loadTemplates(dir string) (*templates, error) {
+	texts := make(map[string]string)
+	for name, text := range builtinTemplates {
+		texts[name] = text
+	}
+	if dir != "" {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, f := range files {
+			if f.IsDir() || filepath.Ext(f.Name()) != ".html" {
+				continue
+			}
+			data, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+			if err != nil {
+				return nil, err
+			}
+			texts[f.Name()] = string(data)
+		}
+	}
+
+	names := make([]string, 0, len(texts))
+	for name := range texts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([][]byte, 0, 2*len(names))
+	for _, name := range names {
+		parts = append(parts, []byte(name), []byte(texts[name]))
+	}
+
+	ts := &templates{pages: make(map[string]*template.Template), hash: hashOf(parts...)}
+	for _, page := range []string{pageTemplate, artifactTemplate, indexTemplate, diffTemplate} {
+		t := template.New(page).Funcs(templateFuncs)
+		for _, name := range names {
+			if isPage(name) && name != page {
+				continue
+			}
+			if _, err := t.New(name).Parse(texts[name]); err != nil {
+				return nil, fmt.Errorf("%v: %v", templateFile(dir, name), err)
+			}
+		}
+		ts.pages[page] = t.Lookup(page)
+	}
+	return ts, nil
+}
+
+func isPage(name string) bool {
+	return name == pageTemplate || name == artifactTemplate || name == indexTemplate || name == diffTemplate
+}
+
+// where the template `name` came from, for messages
+func templateFile(dir, name string) string {
+	if dir != "" {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return filepath.Join(dir, name)
+		}
+	}
+	return "built-in template " + name
+}
+
+// render the page `name` with `data`
+func (ts *templates) render(name string, data interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := ts.pages[name].Execute(buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// write the built-in templates into `dir`, creating it, for editing. Files
+// already there are left alone unless `overwrite` is set. Returns the paths
+// written.
+func ExportTemplates(dir string, overwrite bool) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(builtinTemplates))
+	for name := range builtinTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var written []string
+	for _, name := range names {
+		p := filepath.Join(dir, name)
+		if _, err := os.Stat(p); err == nil && !overwrite {
+			continue
+		}
+		if err := ioutil.WriteFile(p, []byte(builtinTemplates[name]), 0644); err != nil {
+			return written, err
+		}
+		written = append(written, p)
+	}
+	return written, nil
+}
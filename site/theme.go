@@ -0,0 +1,187 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/alecthomas/chroma"
+	"github.com/alecthomas/chroma/formatters/html"
+	"github.com/alecthomas/chroma/styles"
+)
+
+// ## Themes
+// Code is colored by a chroma style, `style` in `lazylit.yaml`, whose CSS is
+// generated with the rest of `gocco.css` on every build. The default, `docco`,
+// is the palette lazylit always had. Pages also have a dark theme, colored
+// by `dark_style`: it follows the reader's system setting, and a button on
+// every page switches between the two, remembered by the browser.
+//
+// The page itself, outside the code, is colored through CSS variables, set
+// for each theme below.
+
+// where the script switching themes goes, within the output directory
+const themeScriptName = "theme.js"
+
+// the default styles
+const (
+	defaultStyle     = "docco"
+	defaultDarkStyle = "monokai"
+)
+
+// Docco's colors, as a chroma style
+var _ = styles.Register(chroma.MustNewStyle(defaultStyle, chroma.StyleEntries{
+	chroma.Background:     "#252519 bg:#f5f5ff",
+	chroma.Whitespace:     "#bbbbbb",
+	chroma.Comment:        "italic #408080",
+	chroma.CommentPreproc: "noitalic #BC7A00",
+
+	chroma.Keyword:            "#954121",
+	chroma.KeywordDeclaration: "bold",
+	chroma.KeywordNamespace:   "bold",
+	chroma.KeywordReserved:    "bold",
+	chroma.KeywordType:        "#B00040",
+
+	chroma.Operator:     "#666666",
+	chroma.OperatorWord: "bold #AA22FF",
+
+	chroma.NameAttribute:  "#7D9029",
+	chroma.NameBuiltin:    "#954121",
+	chroma.NameClass:      "bold #0000FF",
+	chroma.NameConstant:   "#880000",
+	chroma.NameDecorator:  "#AA22FF",
+	chroma.NameEntity:     "bold #999999",
+	chroma.NameException:  "bold #D2413A",
+	chroma.NameFunction:   "#0000FF",
+	chroma.NameLabel:      "#A0A000",
+	chroma.NameNamespace:  "bold #0000FF",
+	chroma.NameTag:        "bold #954121",
+	chroma.NameVariable:   "#19469D",
+	chroma.LiteralNumber:  "#666666",
+	chroma.LiteralString:  "#219161",
+	chroma.StringDoc:      "italic",
+	chroma.StringEscape:   "bold #BB6622",
+	chroma.StringInterpol: "bold #BB6688",
+	chroma.StringOther:    "#954121",
+	chroma.StringRegex:    "#BB6688",
+	chroma.StringSymbol:   "#19469D",
+
+	chroma.GenericDeleted:    "#A00000",
+	chroma.GenericEmph:       "italic",
+	chroma.GenericError:      "#FF0000",
+	chroma.GenericHeading:    "bold #000080",
+	chroma.GenericInserted:   "#00A000",
+	chroma.GenericOutput:     "#808080",
+	chroma.GenericPrompt:     "bold #000080",
+	chroma.GenericStrong:     "bold",
+	chroma.GenericSubheading: "bold #800080",
+	chroma.GenericTraceback:  "#0040D0",
+}))
+
+// the page colors of each theme
+const (
+	lightColors = `
+  --text: #252519;
+  --muted: rgba(0, 0, 0, 0.5);
+  --link: #261a3b;
+  --page: white;
+  --panel: white;
+  --shadow: #777;
+  --rule: #e5e5ee;
+  --input: #dedede;
+  --inline-code: #f8f8ff;
+  --hover: #f5f5ff;
+`
+	darkColors = `
+  --text: #d8d8d0;
+  --muted: rgba(255, 255, 255, 0.5);
+  --link: #a9b8ff;
+  --page: #1b1b1f;
+  --panel: #26262c;
+  --shadow: #000;
+  --rule: #3a3a44;
+  --input: #4a4a55;
+  --inline-code: #2c2c33;
+  --hover: #33333c;
+`
+)
+
+// the selectors that apply a theme: the one the reader chose, or, when they
+// didn't choose, the one their system prefers. Each theme has its own
+// scopes, so that rules of one style never leak into the other theme.
+const (
+	lightScope  = `:root[data-theme="light"]`
+	darkScope   = `:root[data-theme="dark"]`
+	systemScope = `:root:not([data-theme])`
+)
+
+// the media queries matching the system's preference
+const (
+	systemLight = `not all and (prefers-color-scheme: dark)`
+	systemDark  = `(prefers-color-scheme: dark)`
+)
+
+// whether `style` names a chroma style
+func validStyle(style string) bool {
+	return styles.Registry[style] != nil
+}
+
+// the site's stylesheet, with the code colored by the chroma `style` and,
+// unless `dark` is empty, a dark theme with the code colored by `dark`
+func stylesheet(style, dark string) ([]byte, error) {
+	if style == "" {
+		style = defaultStyle
+	}
+	buf := bytes.NewBufferString(Css)
+	fmt.Fprintf(buf, "\n/*--------------------- Light theme: %v ----------------------------*/\n", style)
+	if dark == "" {
+		// with a single theme, there is nothing to keep it from
+		if err := writeTheme(buf, ":root", lightColors, style); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	if err := writeScopedTheme(buf, lightScope, systemLight, lightColors, style); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(buf, "\n/*--------------------- Dark theme: %v ----------------------------*/\n", dark)
+	if err := writeScopedTheme(buf, darkScope, systemDark, darkColors, dark); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// write the rules of a theme twice: for the pages where the reader chose it
+// (`scope`), and for those where they didn't but their system matches `media`
+func writeScopedTheme(buf *bytes.Buffer, scope, media, colors, style string) error {
+	if err := writeTheme(buf, scope, colors, style); err != nil {
+		return err
+	}
+	fmt.Fprintf(buf, "@media %v {\n", media)
+	if err := writeTheme(buf, systemScope, colors, style); err != nil {
+		return err
+	}
+	buf.WriteString("}\n")
+	return nil
+}
+
+// write the rules of a theme, applying to the pages matching `scope`
+func writeTheme(buf *bytes.Buffer, scope, colors, style string) error {
+	s := styles.Get(style)
+	bg := s.Get(chroma.Background)
+	fmt.Fprintf(buf, "%v {%v", scope, colors)
+	if bg.Background.IsSet() {
+		fmt.Fprintf(buf, "  --code: %v;\n", bg.Background)
+	} else {
+		buf.WriteString("  --code: var(--page);\n")
+	}
+	buf.WriteString("}\n")
+
+	rules := new(bytes.Buffer)
+	if err := html.New(html.WithClasses(true)).WriteCSS(rules, s); err != nil {
+		return err
+	}
+	// every section's code sits in its own `.highlight` block, while chroma
+	// only wraps the first one in `.chroma`
+	buf.Write(bytes.Replace(rules.Bytes(), []byte(".chroma"), []byte(scope+" body .highlight"), -1))
+	return nil
+}
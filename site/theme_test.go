@@ -0,0 +1,25 @@
+package site
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStylesheetScopesEachTheme(t *testing.T) {
+	css, err := stylesheet("docco", "monokai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(string(css), "\n") {
+		if !strings.Contains(line, "body .highlight") {
+			continue
+		}
+		// a rule for every page would also color the code of the other theme
+		if strings.Contains(line, ":root body") {
+			t.Errorf("code rule not scoped to a theme: %v", line)
+		}
+	}
+	if !strings.Contains(string(css), lightScope+" body .highlight .kd { color: #954121; font-weight: bold }") {
+		t.Errorf("no light rule for declarations")
+	}
+}
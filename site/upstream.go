@@ -0,0 +1,100 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ## Staleness
+// Notes describe the code at one commit, and nobody expects them to keep up.
+// Readers still like to know how far behind they are, so with `upstream`
+// pointing at a local clone of the documented repository, every page says
+// how many commits have touched its file since, and how much of the code it
+// explains is still there.
+
+// a `Staleness` compares a snapshot to the upstream file at HEAD
+type Staleness struct {
+	// the upstream HEAD, abbreviated
+	Head string
+	// the commits that touched the file since the snapshot's commit
+	Commits int
+	// how many of the snapshot's code sections still appear verbatim at
+	// HEAD, out of `Sections`
+	Unchanged, Sections int
+}
+
+// whether no commit touched the file since the snapshot
+func (st *Staleness) Current() bool {
+	return st.Commits == 0
+}
+
+// the badge text, e.g. `3 commits behind 1a2b3c4; 5 of 7 sections unchanged`
+func (st *Staleness) Summary() string {
+	if st.Current() {
+		return "Up to date with " + st.Head
+	}
+	commits := "commits"
+	if st.Commits == 1 {
+		commits = "commit"
+	}
+	return fmt.Sprintf("%v %v behind %v; %v of %v sections unchanged", st.Commits, commits, st.Head, st.Unchanged, st.Sections)
+}
+
+// a local clone of the documented repository
+type upstream struct {
+	dir string
+	// the full hash of HEAD when the build started
+	head string
+}
+
+// open the clone in `dir`
+func openUpstream(dir string) (*upstream, error) {
+	head, err := Git(dir, "rev-parse", "--verify", "HEAD^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("upstream %v: %v", dir, err)
+	}
+	return &upstream{dir, strings.TrimSpace(string(head))}, nil
+}
+
+// how far the snapshot `a`, split into `sections`, is behind HEAD
+func (u *upstream) staleness(a Snapshot, sections []*Section) (*Staleness, error) {
+	out, err := Git(u.dir, "rev-list", "--count", a.Commit+".."+u.head, "--", a.SourceFileName)
+	if err != nil {
+		return nil, err
+	}
+	commits, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil {
+		return nil, fmt.Errorf("git rev-list: %v", err)
+	}
+	// a file deleted upstream simply has nothing left in common
+	current, _ := Git(u.dir, "show", u.head+":"+a.SourceFileName)
+
+	st := &Staleness{Head: u.head[:7], Commits: commits}
+	for _, sec := range sections {
+		code := bytes.TrimRight(bytes.TrimLeft(sec.codeText, "\n"), " \t\n")
+		if len(code) == 0 {
+			continue
+		}
+		st.Sections++
+		if bytes.Contains(current, code) {
+			st.Unchanged++
+		}
+	}
+	return st, nil
+}
+
+// run git in `dir` and return its raw output. Errors carry git's own
+// message.
+func Git(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %v: %v", strings.Join(args, " "), strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
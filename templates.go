@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/dsabsay/lazylit/site"
+)
+
+// ## The `templates` command
+// `lazylit templates export` writes the built-in page templates and partials
+// into `templates/`, where they take the place of the built-ins and can be
+// edited, e.g. to put a company header and footer on every page.
+
+func runTemplates(args []string) {
+	fs := flag.NewFlagSet("templates", flag.ExitOnError)
+	dir := fs.String("dir", "", "Where to write the templates (default: templates from the config file, or templates).")
+	force := fs.Bool("force", false, "Overwrite templates that are already there.")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: lazylit templates export [-dir dir] [-force]\n\n"+
+			"    Write the built-in templates into dir for editing. lazylit uses\n"+
+			"    them instead of the built-ins from then on. Templates already in\n"+
+			"    dir are kept unless -force is given.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	// the flags follow the subcommand, and parsing stops at the first
+	// argument that isn't a flag
+	if len(args) == 0 || args[0] != "export" {
+		fs.Parse(args)
+		fs.Usage()
+		os.Exit(2)
+	}
+	fs.Parse(args[1:])
+	if fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *dir == "" {
+		*dir = currentConfig().Templates
+	}
+
+	written, err := site.ExportTemplates(*dir, *force)
+	for _, p := range written {
+		fmt.Println(p)
+	}
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	if len(written) == 0 {
+		fmt.Printf("Every template is already in %v; use -force to overwrite them.\n", *dir)
+	}
+}
@@ -3,6 +3,8 @@
 // SourceFile: lazylit.go
 // SourceLink: https://github.com/dsabsay/lazylit/blob/1f1a39ac4217e834caa42b7a50961802dc593f18/lazylit.go
 // DocAuthor: Daniel Sabsay
+// Summary: The command-line entry point, from when lazylit was one file
+// Tags: go, cli
 
 // **lazylit** is a code documentation tool that generates static HTML
 // that can be published via e.g. GitHub Pages and linked from anywhere
@@ -0,0 +1,72 @@
+// lazylit artifacts: sorts and filters the front page's table of artifacts
+(function () {
+  var table = document.getElementById("artifacts");
+  var form = document.getElementById("artifact-filter");
+  if (!table || !form) {
+    return;
+  }
+  var text = document.getElementById("artifact-text");
+  var tag = document.getElementById("artifact-tag");
+  var none = document.getElementById("artifact-none");
+  var body = table.tBodies[0];
+  var rows = Array.prototype.slice.call(body.rows);
+  form.hidden = false;
+
+  function tags(row) {
+    return Array.prototype.map.call(row.querySelectorAll(".tag"), function (t) { return t.textContent; });
+  }
+
+  // show the rows containing every word typed, with the chosen tag
+  function filter() {
+    var terms = text.value.toLowerCase().split(/\s+/).filter(Boolean);
+    var shown = 0;
+    rows.forEach(function (row) {
+      var content = row.textContent.toLowerCase();
+      var match = terms.every(function (term) { return content.indexOf(term) >= 0; }) &&
+        (!tag || tag.value === "" || tags(row).indexOf(tag.value) >= 0);
+      row.hidden = !match;
+      if (match) {
+        shown++;
+      }
+    });
+    none.hidden = shown > 0;
+  }
+
+  function key(row, column) {
+    var cell = row.cells[column];
+    return cell.getAttribute("data-key") || cell.textContent.trim().toLowerCase();
+  }
+
+  // sort by the column of the header th, the other way round if it already is
+  function sort(th) {
+    var column = th.cellIndex;
+    var numeric = th.getAttribute("data-type") === "number";
+    var ascending = th.getAttribute("aria-sort") !== "ascending";
+    Array.prototype.forEach.call(th.parentNode.cells, function (cell) { cell.removeAttribute("aria-sort"); });
+    th.setAttribute("aria-sort", ascending ? "ascending" : "descending");
+    rows.sort(function (a, b) {
+      var x = key(a, column), y = key(b, column);
+      var order = numeric ? x - y : x < y ? -1 : x > y ? 1 : 0;
+      return ascending ? order : -order;
+    });
+    rows.forEach(function (row) { body.appendChild(row); });
+  }
+
+  table.tHead.addEventListener("click", function (event) {
+    var button = event.target.closest("button");
+    if (button) {
+      sort(button.parentNode);
+    }
+  });
+  body.addEventListener("click", function (event) {
+    if (tag && event.target.classList.contains("tag")) {
+      tag.value = event.target.textContent;
+      filter();
+    }
+  });
+  text.addEventListener("input", filter);
+  if (tag) {
+    tag.addEventListener("change", filter);
+  }
+  filter();
+})();
@@ -0,0 +1 @@
+window.lazylitSearchIndex = [{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-2","section":2,"text":"lazylit is a code documentation tool that generates static HTML that can be published via e.g. GitHub Pages and linked from anywhere (code comments, READMEs, Jira tickets, etc.). The code is based on gocco which is a Go port of Docco. It produces HTML that displays your comments alongside your code. Comments are passed through Markdown, and code is passed through Pygments syntax highlighting. The source for lazylit is available on GitHub, and released under the MIT license.","identifiers":["main"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-3","section":3,"text":"Types Due to Go’s statically typed nature, what is passed around in object literals in Docco, requires various structures"},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-4","section":4,"text":"A Section captures a piece of documentation and code Every time interleaving code is found between two comments a new Section is created.","identifiers":["Section","docsText","codeText","DocsHTML","CodeHTML"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-5","section":5,"text":"a TemplateSection is a section that can be passed to Go’s templating system, which expects strings.","identifiers":["TemplateSection","DocsHTML","CodeHTML"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-6","section":6,"text":"The Index field is used to create anchors to sections","identifiers":["Index"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-7","section":7,"text":"a Language describes a programming language","identifiers":["Language","name","symbol","commentMatcher","regexp","Regexp","dividerText","dividerHTML","headerParser"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-8","section":8,"text":"a TemplateData is per-file","identifiers":["TemplateData","Title","Sections","TemplateSection","OtherRevisions","ArtifactSnapshot"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-9","section":9,"text":"Only generate the TOC if there is more than one file (Multiple == true). Go’s templating system does not allow expressions in the template, so calculate it outside","identifiers":["Multiple","Snapshot","ArtifactSnapshot"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-10","section":10,"text":"a map of all the languages we know","identifiers":["languages","Language"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-11","section":11,"text":"Constants","identifiers":["VERSION","DESCRIPTION"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-12","section":12,"text":"Wrap the code in these","identifiers":["highlightStart","highlightEnd"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-13","section":13,"text":"Command-line flags","identifiers":["versionFlag","flag","Bool","helpFlag"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-14","section":14,"text":"Main documentation generation functions"},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-15","section":15,"text":"Generate the documentation for a single source file by splitting it into sections, highlighting each section and putting it together. The WaitGroup is used to signal we are done, so that the main goroutine waits for all the sub goroutines","identifiers":["generateDocumentation","ArtifactSnapshot","otherRevs","wg","sync","WaitGroup","code","err","ioutil","ReadFile","DocFileName","log","Panic","sections","parse","FirstNonHeaderLine","highlight","generateHTML","Done"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-16","section":16,"text":"Parse splits code into Sections","identifiers":["parse","source","code","startLine","list","List","lines","bytes","Split","byte","sections","new","Init","language","getLanguage","hasCode","codeText","Buffer","docsText"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-17","section":17,"text":"save a new section","identifiers":["save","docs","code"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-18","section":18,"text":"deep copy the slices since slices always refer to the same storage by default","identifiers":["docsCopy","codeCopy","make","len","docs","code","copy","sections","PushBack","Section","startLine","lines","line"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-19","section":19,"text":"if the line is a comment","identifiers":["language","commentMatcher","Match","line"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-20","section":20,"text":"but there was previous code","identifiers":["hasCode"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-21","section":21,"text":"we need to save the existing documentation and text as a section and start a new section since code blocks have to be delimited before being sent to Pygments","identifiers":["save","docsText","Bytes","codeText","hasCode","Reset","Write","language","commentMatcher","ReplaceAll","line","WriteString"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-22","section":22,"text":"save any remaining parts of the source file","identifiers":["save","docsText","Bytes","codeText","sections"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-23","section":23,"text":"highlight pipes the source to Pygments, section by section delimited by dividerText, then reads back the highlighted output, searches for the delimiters and extracts the HTML version of the code and documentation for each Section","identifiers":["highlight","source","sections","list","List","language","getLanguage","pygments","exec","Command","name","pygmentsInput","StdinPipe","pygmentsOutput","StdoutPipe"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-24","section":24,"text":"start the process before we start piping data to it otherwise the pipe may block","identifiers":["pygments","Start","sections","Front","Next","pygmentsInput","Write","Value","Section","codeText","io","WriteString","language","dividerText","Close","buf","new","bytes","Buffer","Copy","pygmentsOutput","output","Bytes","Replace","byte","highlightStart","highlightEnd","index","dividerHTML","FindIndex","len","fragment","CodeHTML","Join","DocsHTML","blackfriday","MarkdownCommon","docsText"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-25","section":25,"text":"render the final HTML","identifiers":["generateHTML","ArtifactSnapshot","otherRevs","sections","list","List"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-26","section":26,"text":"convert every Section into corresponding TemplateSection","identifiers":["sectionsArray","make","TemplateSection","sections","Len","Front","Next","sec","Value","Section","docsBuf","bytes","NewBuffer","DocsHTML","codeBuf","CodeHTML","String"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-27","section":27,"text":"run through the Go template","identifiers":["html","goccoTemplate","TemplateData","filepath","Base","SourceFileName","sectionsArray","otherRevs","len"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-28","section":28,"text":"Replace sources with the revisions for this file html := goccoTemplate(TemplateData{title, sectionsArray, sources, len(sources) \u003e 1})","identifiers":["log","Println","DocFileName","Destination","ioutil","WriteFile","html","goccoTemplate","data","TemplateData"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-29","section":29,"text":"this hack is required because ParseFiles doesn’t seem to work properly, always complaining about empty templates","identifiers":["err","template","New","Funcs"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-30","section":30,"text":"introduce the two functions that the template needs","identifiers":["template","FuncMap","filepath","Base","ArtifactSnapshot","Destination","Parse","HTML","err","panic","buf","new","bytes","Buffer","Execute","data","Bytes"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-31","section":31,"text":"get a Language given a path","identifiers":["getLanguage","source","Language","languages","filepath","Ext"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-32","section":32,"text":"make sure docs/ exists","identifiers":["ensureDirectory","name","os","MkdirAll","setupLanguages","languages","make","Language"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-33","section":33,"text":"you can add more languages here. only the first two fields should change, the rest should be nil, \"\", nil","identifiers":["languages","Language","setup","setupLanguages"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-34","section":34,"text":"create the regular expressions based on the language comment symbol","identifiers":["lang","languages","headerParser","regexp","Compile","symbol","commentMatcher","dividerText","dividerHTML"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-35","section":35,"text":"An ArtifactSnapshot represents a single file under the artifacts/ directory. It’s intended to represent a given source code file at a specific point in time.","identifiers":["ArtifactSnapshot","ArtifactName","Commit","CommitDate","time","Time","CommitDateString","SourceFileName","SourceLink","DocFileName","DocAuthor","Dest","FirstNonHeaderLine"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-36","section":36,"text":"This is how we make ArtifactSnapshots sortable by CommitDate. See Sorting by Functions.","identifiers":["byCommitDate","ArtifactSnapshot","Len","len","Swap","Less","CommitDate","Before","Destination","baseName","filepath","Base","DocFileName","ext","Ext","destBase","Join","ArtifactName","IndexTemplateData","Snapshots"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-37","section":37,"text":"Each “artifact” in lazylit is stored in its own subdirectory of artifacts/. For example, artifacts/crazy_makefile/ might store all versions of documentation a particular project’s Makefile. The reason for this abstraction (instead of an identifier composed of e.g. the source repository name and filename) is that this allows for the source filename and location to change over time. Lazylit can keep track of all such versions under a single “artifact”. Each artifact gets an index page which lists all available documented versions.","identifiers":["generateIndexes","artifacts","ArtifactSnapshot","err","template","New","Funcs","FuncMap","filepath","Base","Parse","INDEX_HTML","log","Fatal","Error","name","snapshots","ensureDirectory","dest","Join","os","Create","Execute","IndexTemplateData"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-38","section":38,"text":"Generates a general “about” page for lazylit.","identifiers":["generateAbout","artifacts","ArtifactSnapshot","err","template","New","Parse","ABOUT_HTML","log","Fatal","Error","artifactNames","make","len","name","append","dest","filepath","Join","os","Create","Execute"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-39","section":39,"text":"Each file under artifacts/ must have several headers: Commit: SHA of git commit this documentation is written for CommitDate: Date of commit in format: Jan 2 2006 SourceFile: Filename of original source code (as it is named in production codebase) SourceLink: A URL to the original source code file. Should be static (i.e. contain the commit SHA). DocAuthor: Name of person writing this documentation. See here for an example of how to define the headers.","identifiers":["parseHeaders","name","file","ArtifactSnapshot","data","err","ioutil","ReadFile","lines","bytes","Split","byte","language","getLanguage","ArtifactName","DocFileName","isMissing","line","matches","headerParser","FindStringSubmatch","string","FirstNonHeaderLine","Commit","date","time","Parse","log","Printf","fmt","Errorf","CommitDate","CommitDateString","SourceFileName","SourceLink","DocAuthor"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-40","section":40,"text":"check for missing headers","identifiers":["missingHeaders","make","missing","isMissing","append","len","fmt","Errorf","file"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-41","section":41,"text":"let’s Go!","identifiers":["main","setup","flag","Usage","fmt","Fprintf","CommandLine","Output","DESCRIPTION","PrintDefaults","Parse","versionFlag","Printf","VERSION","os","Exit","helpFlag","adirs","err","ioutil","ReadDir","IsNotExist","log","Fatalf","Fatal","Error"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-42","section":42,"text":"Parse the contents of artifacts/, generating a list of ArtifactSnapshots.","identifiers":["pageCount","artifacts","make","ArtifactSnapshot","dir","adirs","path","filepath","Join","Name","files","err","ioutil","ReadDir","log","Fatal","Error","file","fpath","snap","parseHeaders","append","sort","Sort","Reverse","byCommitDate","ensureDirectory"]},{"artifact":"lazylit","date":"Jul 18 2020","url":"lazylit/lazylit.jul_18_2020.html#section-43","section":43,"text":"A .nojekyll file ensures GitHub Pages won’t run anything through Jekyll. See https://github.blog/2009-12-29-bypassing-jekyll-on-github-pages/.","identifiers":["err","os","Create","Close","IsNotExist","log","Fatalf","generateAbout","artifacts","generateIndexes","ioutil","WriteFile","bytes","NewBufferString","Css","Bytes","wg","new","sync","WaitGroup","Add","pageCount","snapshot","otherRevs","make","ArtifactSnapshot","len","copy","generateDocumentation","Wait"]}];
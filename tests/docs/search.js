@@ -0,0 +1,101 @@
+// lazylit search: looks through search-index.js as you type
+(function () {
+  var input = document.getElementById("search");
+  var results = document.getElementById("search-results");
+  if (!input || !results) {
+    return;
+  }
+  var root = input.getAttribute("data-root") || "";
+  var index = null;
+  var requested = false;
+
+  function load() {
+    if (requested) {
+      return;
+    }
+    requested = true;
+    // a script, unlike fetched JSON, loads from file:// URLs too
+    var script = document.createElement("script");
+    script.src = root + "search-index.js";
+    script.onload = function () {
+      index = window.lazylitSearchIndex || [];
+      search();
+    };
+    script.onerror = function () {
+      results.innerHTML = "<li>The search index can't be loaded.</li>";
+    };
+    document.head.appendChild(script);
+  }
+
+  function escape(s) {
+    return s.replace(/[&<>"]/g, function (c) {
+      return { "&": "&amp;", "<": "&lt;", ">": "&gt;", "\"": "&quot;" }[c];
+    });
+  }
+
+  // the part of the text around the first occurrence of term
+  function snippet(entry, term) {
+    var text = entry.text;
+    if (!text) {
+      return (entry.identifiers || []).slice(0, 8).join(", ");
+    }
+    var start = Math.max(0, text.toLowerCase().indexOf(term) - 40);
+    return (start > 0 ? "\u2026" : "") + text.slice(start, start + 140) + (start + 140 < text.length ? "\u2026" : "");
+  }
+
+  // every term must match; a matching identifier counts more than text
+  function score(entry, terms) {
+    var text = entry.text.toLowerCase();
+    var ids = (entry.identifiers || []).map(function (id) { return id.toLowerCase(); });
+    var total = 0;
+    for (var i = 0; i < terms.length; i++) {
+      var term = terms[i];
+      if (ids.indexOf(term) >= 0) {
+        total += 3;
+      } else if (ids.some(function (id) { return id.indexOf(term) >= 0; })) {
+        total += 2;
+      } else if (text.indexOf(term) >= 0) {
+        total += 1;
+      } else {
+        return 0;
+      }
+    }
+    return total;
+  }
+
+  function search() {
+    var terms = input.value.toLowerCase().split(/\s+/).filter(Boolean);
+    results.innerHTML = "";
+    if (terms.length === 0 || index === null) {
+      return;
+    }
+    var hits = [];
+    index.forEach(function (entry) {
+      var s = score(entry, terms);
+      if (s > 0) {
+        hits.push({ entry: entry, score: s });
+      }
+    });
+    hits.sort(function (a, b) { return b.score - a.score; });
+    if (hits.length === 0) {
+      results.innerHTML = "<li>No matches</li>";
+      return;
+    }
+    hits.slice(0, 20).forEach(function (hit) {
+      var e = hit.entry;
+      var li = document.createElement("li");
+      li.innerHTML = "<a href=\"" + escape(root + e.url) + "\">" + escape(e.artifact) +
+        " <span class=\"date\">" + escape(e.date) + "</span></a><p>" + escape(snippet(e, terms[0])) + "</p>";
+      results.appendChild(li);
+    });
+  }
+
+  input.addEventListener("focus", load);
+  input.addEventListener("input", function () { load(); search(); });
+  input.addEventListener("keydown", function (event) {
+    if (event.key === "Escape") {
+      input.value = "";
+      search();
+    }
+  });
+})();
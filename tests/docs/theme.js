@@ -0,0 +1,42 @@
+// lazylit theme: applies the reader's choice of light or dark pages, and
+// lets the button in the corner of every page change it
+(function () {
+  var key = "lazylit-theme";
+  var root = document.documentElement;
+  try {
+    var saved = localStorage.getItem(key);
+    if (saved) {
+      root.setAttribute("data-theme", saved);
+    }
+  } catch (e) {
+    // storage can be disabled; the system setting still applies
+  }
+
+  function current() {
+    var theme = root.getAttribute("data-theme");
+    if (theme) {
+      return theme;
+    }
+    return window.matchMedia && window.matchMedia("(prefers-color-scheme: dark)").matches ? "dark" : "light";
+  }
+
+  document.addEventListener("DOMContentLoaded", function () {
+    var button = document.getElementById("theme_toggle");
+    if (!button) {
+      return;
+    }
+    function label() {
+      button.textContent = current() == "dark" ? "Light pages" : "Dark pages";
+    }
+    label();
+    button.addEventListener("click", function () {
+      var theme = current() == "dark" ? "light" : "dark";
+      root.setAttribute("data-theme", theme);
+      try {
+        localStorage.setItem(key, theme);
+      } catch (e) {
+      }
+      label();
+    });
+  });
+})();